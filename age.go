@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"filippo.io/age"
+	"fmt"
+	"io"
+)
+
+// ageVersion is the first line of every age file, which tells an encrypted backup from the plain output of
+// keys combine.
+const ageVersion = "age-encryption.org/v1"
+
+// ageEncrypt encrypts plaintext to every recipient.
+func ageEncrypt(recipients []age.Recipient, plaintext []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipients...)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = w.Write(plaintext); err != nil {
+		return nil, err
+	}
+
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ageDecrypt decrypts data with the first identity that matches one of its recipients.
+func ageDecrypt(identities []age.Identity, data []byte) ([]byte, error) {
+	r, err := age.Decrypt(bytes.NewReader(data), identities...)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read payload: %v", err)
+	}
+
+	return plaintext, nil
+}
+
+// agePassphraseRecipient and agePassphraseIdentity encrypt and decrypt with a passphrase, through the age
+// scrypt recipient type.
+func agePassphraseRecipient(passphrase []byte) (age.Recipient, error) {
+	recipient, err := age.NewScryptRecipient(string(passphrase))
+	if err != nil {
+		return nil, fmt.Errorf("invalid passphrase: %v", err)
+	}
+
+	return recipient, nil
+}
+
+func agePassphraseIdentity(passphrase []byte) (age.Identity, error) {
+	identity, err := age.NewScryptIdentity(string(passphrase))
+	if err != nil {
+		return nil, fmt.Errorf("invalid passphrase: %v", err)
+	}
+
+	return identity, nil
+}
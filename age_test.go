@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"filippo.io/age"
+	"testing"
+)
+
+// The files in testdata/age were encrypted with the age CLI, identity.txt is the key from age-keygen and
+// passphrase.txt the passphrase given to age -p.
+
+func setKeyFlags(t *testing.T, identities []string, passphraseFile string) {
+	oldIdentities, oldPassphraseFile := Identities, PassphraseFile
+	t.Cleanup(func() {
+		Identities, PassphraseFile = oldIdentities, oldPassphraseFile
+	})
+
+	Identities, PassphraseFile = identities, passphraseFile
+}
+
+func TestAgeDecryptCLI(t *testing.T) {
+	plaintext, err := readFile("testdata/age/plaintext.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		file           string
+		identities     []string
+		passphraseFile string
+	}{
+		{name: "x25519", file: "testdata/age/x25519.age", identities: []string{"testdata/age/identity.txt"}},
+		{name: "passphrase", file: "testdata/age/passphrase.age", passphraseFile: "testdata/age/passphrase.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setKeyFlags(t, tt.identities, tt.passphraseFile)
+			identities, err := restoreIdentities()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := readFile(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.HasPrefix(data, []byte(ageVersion+"\n")) {
+				t.Errorf("%s does not start with %q", tt.file, ageVersion)
+			}
+
+			decrypted, err := ageDecrypt(identities, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("decrypted %q, want %q", decrypted, plaintext)
+			}
+		})
+	}
+}
+
+func TestAgeRoundTrip(t *testing.T) {
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setKeyFlags(t, []string{"testdata/age/identity.txt"}, "testdata/age/passphrase.txt")
+	RecipientsFiles = []string{"testdata/age/recipients.txt"}
+	t.Cleanup(func() { RecipientsFiles = nil })
+
+	identities, err := parseIdentities()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recipients, err := parseRecipients()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passphrase, err := readPassphrase("Passphrase", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passphraseRecipient, err := agePassphraseRecipient(passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passphraseIdentity, err := agePassphraseIdentity(passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// larger than a chunk, so that the payload spans several
+	plaintext := bytes.Repeat([]byte("sealed secrets\n"), 10000)
+
+	tests := []struct {
+		name       string
+		recipients []age.Recipient
+		identities []age.Identity
+		wantErr    bool
+	}{
+		{name: "x25519", recipients: recipients, identities: identities},
+		{name: "second of several recipients", recipients: []age.Recipient{other.Recipient(), recipients[0]}, identities: identities},
+		{name: "passphrase", recipients: []age.Recipient{passphraseRecipient}, identities: []age.Identity{passphraseIdentity}},
+		{name: "wrong identity", recipients: []age.Recipient{other.Recipient()}, identities: identities, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted, err := ageEncrypt(tt.recipients, plaintext)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			decrypted, err := ageDecrypt(tt.identities, encrypted)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("decrypted %d bytes, want the %d encrypted", len(decrypted), len(plaintext))
+			}
+		})
+	}
+}
+
+func TestAgeDecryptTampered(t *testing.T) {
+	setKeyFlags(t, []string{"testdata/age/identity.txt"}, "")
+	identities, err := parseIdentities()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := readFile("testdata/age/x25519.age")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data[len(data)-1] ^= 1
+	if _, err := ageDecrypt(identities, data); err == nil {
+		t.Error("decrypted a tampered file")
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+	"time"
+)
+
+var Timeout = 2 * time.Minute
+var ForceConflicts bool
+
+// FieldManager owns the fields of the SealedSecrets this tool applies.
+const FieldManager = "sealedsecrets"
+
+func Apply(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return cmd.Help()
+	}
+
+	files, err := findFiles(args, Recursive, isSealedFile)
+	if err != nil {
+		return err
+	}
+
+	return runBatch(files, nil, !KeepGoing, func(input string, output string) error {
+		return apply(cmd, input)
+	})
+}
+
+// apply server-side applies every SealedSecret in the file, then waits up to --timeout for the controller to
+// unseal each of them.
+func apply(cmd *cobra.Command, arg string) error {
+	data, err := readFile(arg)
+	if err != nil {
+		return err
+	}
+
+	m, err := readManifest(data)
+	if err != nil {
+		return fmt.Errorf("unable to read documents from %s: %v", arg, err)
+	}
+
+	client, err := getKubeClient()
+	if err != nil {
+		return newError(ExitClusterUnreachable, "unable to get kubernetes client: %v", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(client.client)
+	if err != nil {
+		return newError(ExitClusterUnreachable, "unable to create dynamic client: %v", err)
+	}
+
+	var applied []*unstructured.Unstructured
+	for i, doc := range m.documents {
+		kind, err := documentKind(doc)
+		if err != nil {
+			return fmt.Errorf("unable to unmarshal document %d of %s: %v", i+1, arg, err)
+		}
+
+		if kind != "SealedSecret" {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{}
+		err = yaml.Unmarshal(doc, &obj.Object)
+		if err != nil {
+			return fmt.Errorf("unable to unmarshal document %d of %s: %v", i+1, arg, err)
+		}
+
+		ns, err := resolveNamespace(metav1.ObjectMeta{Name: obj.GetName(), Namespace: obj.GetNamespace(), Annotations: obj.GetAnnotations()})
+		if err != nil {
+			return err
+		}
+
+		obj.SetNamespace(ns)
+		result, err := dynamicClient.Resource(sealedSecretsResource).Namespace(ns).Apply(cmd.Context(), obj.GetName(), obj, metav1.ApplyOptions{
+			FieldManager: FieldManager,
+			Force:        ForceConflicts,
+		})
+
+		if apierrors.IsConflict(err) {
+			return fmt.Errorf("unable to apply %s/%s, its fields are managed by another tool, use --force-conflicts to take them over: %v", ns, obj.GetName(), err)
+		} else if err != nil {
+			return newError(ExitClusterUnreachable, "unable to apply %s/%s: %v", ns, obj.GetName(), err)
+		}
+
+		fmt.Printf("Applied '%s/%s' from %s\n", ns, obj.GetName(), arg)
+		applied = append(applied, result)
+	}
+
+	if len(applied) == 0 {
+		return newError(ExitNotFound, "no sealed secrets found in %s", arg)
+	}
+
+	if Timeout == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), Timeout)
+	defer cancel()
+
+	for _, obj := range applied {
+		err = waitForUnseal(ctx, dynamicClient, client, obj)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// waitForUnseal watches the applied SealedSecret until the controller reports on its current generation,
+// then checks that the Secret it reported on is owned by it.
+func waitForUnseal(ctx context.Context, dynamicClient dynamic.Interface, client *ClientConfig, obj *unstructured.Unstructured) error {
+	ns, name := obj.GetNamespace(), obj.GetName()
+	resource := dynamicClient.Resource(sealedSecretsResource).Namespace(ns)
+
+	for {
+		sealedSecret := v1alpha1.SealedSecret{}
+		err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &sealedSecret)
+		if err != nil {
+			return fmt.Errorf("unable to convert sealed secret %s/%s: %v", ns, name, err)
+		}
+
+		if synced := syncedCondition(&sealedSecret); synced != nil {
+			if synced.Status != corev1.ConditionTrue {
+				return newError(ExitDecryptFailed, "controller failed to unseal %s/%s: %s", ns, name, synced.Message)
+			}
+
+			return checkOwnedSecret(ctx, client, &sealedSecret)
+		}
+
+		// watching from the applied version, so that an update made before the watch started is not missed
+		w, err := resource.Watch(ctx, metav1.ListOptions{
+			FieldSelector:   "metadata.name=" + name,
+			ResourceVersion: obj.GetResourceVersion(),
+		})
+
+		if err != nil && ctx.Err() == nil {
+			return newError(ExitClusterUnreachable, "unable to watch %s/%s: %v", ns, name, err)
+		} else if err != nil {
+			return fmt.Errorf("timed out after %s waiting for %s/%s to be unsealed", Timeout, ns, name)
+		}
+
+		updated, err := nextUpdate(ctx, w)
+		w.Stop()
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("timed out after %s waiting for %s/%s to be unsealed", Timeout, ns, name)
+		} else if err != nil {
+			return fmt.Errorf("unable to watch %s/%s: %v", ns, name, err)
+		} else if updated != nil {
+			obj = updated
+		}
+	}
+}
+
+// nextUpdate returns the SealedSecret from the next event of w, or nil if the server closed the watch, in
+// which case the caller watches again.
+func nextUpdate(ctx context.Context, w watch.Interface) (*unstructured.Unstructured, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case event, ok := <-w.ResultChan():
+		if !ok {
+			return nil, nil
+		}
+
+		switch event.Type {
+		case watch.Deleted:
+			return nil, fmt.Errorf("sealed secret was deleted")
+		case watch.Error:
+			return nil, apierrors.FromObject(event.Object)
+		}
+
+		obj, ok := event.Object.(*unstructured.Unstructured)
+		if !ok {
+			return nil, fmt.Errorf("unexpected %T in watch", event.Object)
+		}
+
+		return obj, nil
+	}
+}
+
+// syncedCondition returns the Synced condition of the SealedSecret if the controller has processed its
+// current generation, nil if it has not yet.
+func syncedCondition(sealedSecret *v1alpha1.SealedSecret) *v1alpha1.SealedSecretCondition {
+	if sealedSecret.Status == nil || sealedSecret.Status.ObservedGeneration < sealedSecret.Generation {
+		return nil
+	}
+
+	for i := range sealedSecret.Status.Conditions {
+		if sealedSecret.Status.Conditions[i].Type == v1alpha1.SealedSecretSynced {
+			return &sealedSecret.Status.Conditions[i]
+		}
+	}
+
+	return nil
+}
+
+func checkOwnedSecret(ctx context.Context, client *ClientConfig, sealedSecret *v1alpha1.SealedSecret) error {
+	secret, err := client.clientset.CoreV1().Secrets(sealedSecret.Namespace).Get(ctx, sealedSecret.Name, metav1.GetOptions{})
+	if err != nil {
+		return newError(ExitClusterUnreachable, "unable to get secret %s/%s: %v", sealedSecret.Namespace, sealedSecret.Name, err)
+	}
+
+	if !metav1.IsControlledBy(secret, sealedSecret) {
+		return fmt.Errorf("secret %s/%s is not owned by its sealed secret", sealedSecret.Namespace, sealedSecret.Name)
+	}
+
+	fmt.Printf("Unsealed '%s/%s'\n", sealedSecret.Namespace, sealedSecret.Name)
+	return nil
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var Recursive bool
+var Jobs = 4
+var KeepGoing bool
+
+// UnsealedInfix marks unsealed files, which are named like the sealed file with it before the extension.
+const UnsealedInfix = ".unsealed"
+
+// Extensions are the extensions of the files we read and write, one per FileFormat.
+var Extensions = []string{".yaml", ".json"}
+
+// batchResult is the outcome of processing a single file of a batch.
+type batchResult struct {
+	input   string
+	output  string
+	err     error
+	skipped bool
+}
+
+// sealOutputName names the sealed file of name by dropping UnsealedInfix, or by adding the extension if name
+// does not have it, so that the sealed file is never the input itself.
+func sealOutputName(name string) string {
+	format := fileFormat(name)
+	for _, ext := range Extensions {
+		name = strings.TrimSuffix(name, UnsealedInfix+ext)
+	}
+
+	name = strings.TrimSuffix(name, UnsealedInfix+".yml")
+	return name + "." + string(format)
+}
+
+func unsealOutputName(name string) string {
+	format := fileFormat(name)
+	for _, ext := range Extensions {
+		name = strings.TrimSuffix(name, ext)
+	}
+
+	return name + UnsealedInfix + "." + string(format)
+}
+
+// isUnsealedFile reports whether name is an unsealed file. .yml is included as other tools write it.
+func isUnsealedFile(name string) bool {
+	for _, ext := range Extensions {
+		if strings.HasSuffix(name, UnsealedInfix+ext) {
+			return true
+		}
+	}
+
+	return strings.HasSuffix(name, UnsealedInfix+".yml")
+}
+
+// isSealedFile reports whether name is a .yaml or .json file holding at least one SealedSecret.
+func isSealedFile(name string) bool {
+	if filepath.Ext(name) != ".yaml" && filepath.Ext(name) != ".json" || isUnsealedFile(name) {
+		return false
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return false
+	}
+
+	m, err := readManifest(data)
+	if err != nil {
+		return false
+	}
+
+	for _, doc := range m.documents {
+		if kind, err := documentKind(doc); err == nil && kind == "SealedSecret" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isDir(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && info.IsDir()
+}
+
+// expandGlobs replaces every arg that does not exist but is a glob pattern (e.g. quoted to stop the shell
+// expanding it) with the directories and matching files it matches.
+func expandGlobs(args []string, match func(name string) bool) ([]string, error) {
+	expanded := make([]string, 0, len(args))
+	for _, arg := range args {
+		if _, err := os.Stat(arg); err == nil || !strings.ContainsAny(arg, "*?[") {
+			expanded = append(expanded, arg)
+			continue
+		}
+
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %v", arg, err)
+		}
+
+		n := len(expanded)
+		for _, name := range matches {
+			if isDir(name) || match(name) {
+				expanded = append(expanded, name)
+			}
+		}
+
+		if len(expanded) == n {
+			return nil, newError(ExitNotFound, "no files match %s", arg)
+		}
+	}
+
+	return expanded, nil
+}
+
+// findFiles returns the files named by args, expanding glob patterns. Directories are only accepted if
+// recursive is set, usually by --recursive, in which case every file below them that matches is included.
+// Hidden directories such as .git are skipped.
+func findFiles(args []string, recursive bool, match func(name string) bool) ([]string, error) {
+	return walkFiles(args, recursive, match, func(name string) bool {
+		return strings.HasPrefix(name, ".")
+	})
+}
+
+// walkFiles is findFiles with the directories to skip below args chosen by skipDir, which is given their base
+// name.
+func walkFiles(args []string, recursive bool, match func(name string) bool, skipDir func(name string) bool) ([]string, error) {
+	args, err := expandGlobs(args, match)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(args))
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+
+		if !recursive {
+			return nil, fmt.Errorf("%s is a directory, use --recursive to process directories", arg)
+		}
+
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if d.IsDir() {
+				if path != arg && skipDir(d.Name()) {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+
+			if match(path) {
+				files = append(files, path)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// runBatch calls fn for every file using up to --jobs workers, then prints a summary. If stopOnFailure
+// is set, no further files are started once a file fails. outputName may be nil if there is no output.
+func runBatch(files []string, outputName func(string) string, stopOnFailure bool, fn func(input string, output string) error) error {
+	jobs := Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	results := make([]batchResult, len(files))
+	for i, file := range files {
+		results[i] = batchResult{input: file, skipped: true}
+		if outputName != nil {
+			results[i].output = outputName(file)
+		}
+	}
+
+	var mu sync.Mutex
+	failed := false
+
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				mu.Lock()
+				stop := failed
+				mu.Unlock()
+
+				if stop {
+					continue
+				}
+
+				err := fn(results[i].input, results[i].output)
+
+				mu.Lock()
+				results[i].err = err
+				results[i].skipped = false
+				if err != nil && stopOnFailure {
+					failed = true
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i := range files {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	return printSummary(results)
+}
+
+// printSummary prints the outcome of every file, returning an error if any of them failed. The error
+// carries the exit code of the failures if they all share one.
+func printSummary(results []batchResult) error {
+	succeeded, failed, skipped := 0, 0, 0
+	code := 0
+	for _, r := range results {
+		switch {
+		case r.skipped:
+			skipped++
+		case r.err != nil:
+			failed++
+			if code == 0 {
+				code = exitCode(r.err)
+			} else if code != exitCode(r.err) {
+				code = ExitFailure
+			}
+		default:
+			succeeded++
+		}
+	}
+
+	fmt.Printf("\nProcessed %d files: %d succeeded, %d failed, %d skipped\n", len(results), succeeded, failed, skipped)
+	for _, r := range results {
+		switch {
+		case r.skipped:
+			fmt.Printf("  skipped  %s\n", r.input)
+		case r.err != nil:
+			fmt.Printf("  failed   %s: %v\n", r.input, r.err)
+		case r.output == "":
+			fmt.Printf("  ok       %s\n", r.input)
+		default:
+			fmt.Printf("  ok       %s -> %s\n", r.input, r.output)
+		}
+	}
+
+	if failed > 0 {
+		return newError(code, "%d of %d files failed", failed, len(results))
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestSealOutputName(t *testing.T) {
+	tests := []struct {
+		name   string
+		format FileFormat
+		file   string
+		want   string
+	}{
+		{name: "unsealed yaml", file: "db.unsealed.yaml", want: "db.yaml"},
+		{name: "unsealed json", file: "db.unsealed.json", want: "db.json"},
+		{name: "unsealed yml", file: "db.unsealed.yml", want: "db.yaml"},
+		{name: "unsealed to json", format: FormatJSON, file: "db.unsealed.yaml", want: "db.json"},
+		{name: "no infix is not the input", file: "db.yaml", want: "db.yaml.yaml"},
+		{name: "no extension", file: "db", want: "db.yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := Format
+			t.Cleanup(func() { Format = old })
+			Format = tt.format
+
+			if got := sealOutputName(tt.file); got != tt.want {
+				t.Errorf("sealOutputName(%q) = %s, want %s", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSealOutputIsInput(t *testing.T) {
+	err := seal(nil, "db.yaml", "./db.yaml")
+	if err == nil {
+		t.Fatal("sealing a file onto itself succeeded")
+	}
+}
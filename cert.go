@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"github.com/bitnami-labs/sealed-secrets/pkg/crypto"
+	"github.com/bitnami-labs/sealed-secrets/pkg/kubeseal"
+	"github.com/spf13/cobra"
+	"io"
+	corev1 "k8s.io/api/core/v1"
+	certutil "k8s.io/client-go/util/cert"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CertMaxAge is how long a cached controller certificate is used before it is fetched again.
+var CertMaxAge = 24 * time.Hour
+
+// CertExpiryWarning is how long before its NotAfter date a warning is printed for a certificate.
+const CertExpiryWarning = 30 * 24 * time.Hour
+
+func CertFetch(cmd *cobra.Command, args []string) error {
+	cert, source, err := getCachedCert(cmd.Context(), true)
+	if err != nil {
+		return err
+	}
+
+	return printCert(cert, source)
+}
+
+func CertShow(cmd *cobra.Command, args []string) error {
+	cert, source, err := getCert(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	return printCert(cert, source)
+}
+
+func printCert(cert *x509.Certificate, source string) error {
+	fingerprint, err := crypto.PublicKeyFingerprint(cert.PublicKey.(*rsa.PublicKey))
+	if err != nil {
+		return fmt.Errorf("unable to fingerprint certificate: %v", err)
+	}
+
+	fmt.Printf("Source:      %s\n", source)
+	fmt.Printf("Fingerprint: %s\n", fingerprint)
+	fmt.Printf("Not before:  %s\n", cert.NotBefore.Format(time.RFC3339))
+	fmt.Printf("Not after:   %s\n", cert.NotAfter.Format(time.RFC3339))
+
+	warnExpiry(cert, source)
+	return nil
+}
+
+// getCert returns the certificate to seal with and where it came from: --cert, the cert configured for the
+// current context, or the controller by way of the local cache.
+func getCert(ctx context.Context) (*x509.Certificate, string, error) {
+	certURL := CertURL
+	if certURL == "" {
+		contextConfig, err := getContextConfig(currentContext())
+		if err != nil {
+			return nil, "", err
+		}
+
+		certURL = contextConfig.Cert
+	}
+
+	if certURL == "" {
+		return getCachedCert(ctx, false)
+	}
+
+	data, err := readCert(ctx, certURL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	cert, err := parseCert(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return cert, certURL, nil
+}
+
+// getCachedCert returns the controller certificate from the cache. It is fetched again if refresh is set, the
+// cached copy is older than CertMaxAge, or the newest active controller key is not the one in the cached copy.
+// A stale copy is still used if the controller cannot be reached.
+func getCachedCert(ctx context.Context, refresh bool) (*x509.Certificate, string, error) {
+	name, err := certCachePath()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var newest *x509.Certificate
+	cached, modTime := readCachedCert(name)
+	if cached != nil && !refresh && time.Since(modTime) < CertMaxAge {
+		newest, err = newestKeyCert(ctx)
+		if err != nil {
+			// listing the keys needs access to the controller namespace, which not everyone who seals has
+			keyCheckOnce.Do(func() {
+				debugf("unable to check the cached certificate against the controller keys: %v", err)
+			})
+
+			return cached, name, nil
+		}
+
+		if newest.Equal(cached) {
+			return cached, name, nil
+		}
+
+		fmt.Fprintf(os.Stderr, "The controller has a newer key than the certificate cached at %s, fetching it again\n", modTime.Format(time.RFC3339))
+	}
+
+	data, err := readCert(ctx, "")
+	if err != nil && cached != nil && !refresh {
+		WarningLogger.Printf("unable to refresh certificate, using the copy cached at %s: %v", modTime.Format(time.RFC3339), err)
+		return cached, name, nil
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	cert, err := parseCert(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if cached != nil && !cached.Equal(cert) {
+		WarningLogger.Printf("the controller certificate changed since %s, use reencrypt to rotate files sealed with the old one", modTime.Format(time.RFC3339))
+	}
+
+	if newest != nil && !newest.Equal(cert) {
+		WarningLogger.Printf("the controller serves an older certificate than its newest key, restart it to seal with the newest key")
+	}
+
+	err = writeCachedCert(name, data)
+	if err != nil {
+		WarningLogger.Printf("unable to cache certificate: %v", err)
+	}
+
+	return cert, name, nil
+}
+
+// certCachePath returns the cache file for the certificate of the controller in the current context.
+func certCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to get user cache directory: %v", err)
+	}
+
+	name := fmt.Sprintf("%s_%s_%s.pem", url.PathEscape(currentContext()), ControllerNamespace, ControllerName)
+	return filepath.Join(dir, "sealedsecrets", "certs", name), nil
+}
+
+// readCachedCert returns the cached certificate and when it was fetched, or nil if there is no usable copy.
+func readCachedCert(name string) (*x509.Certificate, time.Time) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, time.Time{}
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, time.Time{}
+	}
+
+	cert, err := parseCert(data)
+	if err != nil {
+		return nil, time.Time{}
+	}
+
+	return cert, info.ModTime()
+}
+
+func writeCachedCert(name string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(name), 0700)
+	if err != nil {
+		return err
+	}
+
+	// written to a temporary file first so that concurrent runs never read a partial certificate
+	f, err := os.CreateTemp(filepath.Dir(name), ".cert-*")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+
+	return err
+}
+
+// keyCheckOnce limits the message about an unchecked cache to one per run.
+var keyCheckOnce sync.Once
+
+// newestKeyCert returns the certificate of the newest active controller key.
+func newestKeyCert(ctx context.Context) (*x509.Certificate, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	secrets, err := getKeySecrets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(secrets, func(i, j int) bool {
+		return secrets[j].CreationTimestamp.Before(&secrets[i].CreationTimestamp)
+	})
+
+	return parseCert(secrets[0].Data[corev1.TLSCertKey])
+}
+
+// readCert reads the certificate at certURL, or fetches it from the controller if certURL is empty.
+func readCert(ctx context.Context, certURL string) ([]byte, error) {
+	var client *ClientConfig
+	if certURL == "" {
+		var err error
+		client, err = getKubeClient()
+		if err != nil {
+			return nil, newError(ExitClusterUnreachable, "unable to get kubernetes client: %v", err)
+		}
+	}
+
+	r, err := kubeseal.OpenCert(ctx, client, ControllerNamespace, ControllerName, certURL)
+	if err != nil && certURL == "" {
+		return nil, newError(ExitClusterUnreachable, "unable to open cert: %v", err)
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to open cert: %v", err)
+	}
+
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read cert: %v", err)
+	}
+
+	return data, nil
+}
+
+func parseCert(data []byte) (*x509.Certificate, error) {
+	certs, err := certutil.ParseCertsPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse cert: %v", err)
+	}
+
+	if _, ok := certs[0].PublicKey.(*rsa.PublicKey); !ok {
+		return nil, fmt.Errorf("expected RSA public key but found %T", certs[0].PublicKey)
+	}
+
+	return certs[0], nil
+}
+
+func warnExpiry(cert *x509.Certificate, source string) {
+	if time.Now().After(cert.NotAfter) {
+		WarningLogger.Printf("certificate %s expired on %s", source, cert.NotAfter.Format(time.RFC3339))
+	} else if time.Until(cert.NotAfter) < CertExpiryWarning {
+		WarningLogger.Printf("certificate %s expires on %s", source, cert.NotAfter.Format(time.RFC3339))
+	}
+}
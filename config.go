@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/viper"
+)
+
+const ConfigFileName = "sealedsecrets"
+
+// ContextConfig holds settings that only apply to a single kube context, configured in the config file as:
+//
+//	contexts:
+//	  - name: production
+//	    cert: certs/production.pem
+type ContextConfig struct {
+	Name string `mapstructure:"name"`
+	Cert string `mapstructure:"cert"`
+}
+
+var contextConfigs []ContextConfig
+var contextConfigsLoaded bool
+
+func loadContextConfigs() ([]ContextConfig, error) {
+	if contextConfigsLoaded {
+		return contextConfigs, nil
+	}
+
+	v := viper.New()
+	v.SetConfigName(ConfigFileName)
+	v.AddConfigPath(".")
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("unable to read config file: %v", err)
+		}
+	}
+
+	var configs []ContextConfig
+	if err := v.UnmarshalKey("contexts", &configs); err != nil {
+		return nil, fmt.Errorf("unable to parse contexts from config file: %v", err)
+	}
+
+	contextConfigs = configs
+	contextConfigsLoaded = true
+	return contextConfigs, nil
+}
+
+// getContextConfig returns the config file settings for the named context, or an empty config if there are none.
+func getContextConfig(name string) (ContextConfig, error) {
+	configs, err := loadContextConfigs()
+	if err != nil {
+		return ContextConfig{}, err
+	}
+
+	for _, c := range configs {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+
+	return ContextConfig{Name: name}, nil
+}
+
+// currentContext returns the kube context that will be used, without requiring the cluster to be reachable.
+// It returns an empty string if the context cannot be determined, e.g. when there is no kubeconfig.
+func currentContext() string {
+	if Context != "" {
+		return Context
+	}
+
+	client, err := getKubeClient()
+	if err != nil {
+		return ""
+	}
+
+	return client.context
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"os"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+var ToNamespace string
+var ToName string
+var ToContext string
+
+func Copy(cmd *cobra.Command, args []string) error {
+	if OutputFile == "" {
+		return fmt.Errorf("--output is required")
+	}
+
+	return retarget(cmd, args[0], OutputFile, false)
+}
+
+func Move(cmd *cobra.Command, args []string) error {
+	outputName := OutputFile
+	if outputName == "" {
+		outputName = args[0]
+	}
+
+	return retarget(cmd, args[0], outputName, true)
+}
+
+// retarget decrypts the SealedSecrets in arg and seals them again under --to-name and --to-namespace, with the
+// controller certificate of --to-context, writing them to outputName. The input is removed if move is set and
+// outputName is another file. Scopes are kept, and the plaintext only ever exists in memory.
+func retarget(cmd *cobra.Command, arg string, outputName string, move bool) error {
+	if ToNamespace == "" && ToName == "" && ToContext == "" {
+		return fmt.Errorf("nothing to change, use --to-namespace, --to-name or --to-context")
+	}
+
+	if errs := validation.IsDNS1123Subdomain(ToName); ToName != "" && len(errs) > 0 {
+		return fmt.Errorf("invalid name %s: %s", ToName, strings.Join(errs, ", "))
+	}
+
+	if errs := validation.IsDNS1123Label(ToNamespace); ToNamespace != "" && len(errs) > 0 {
+		return fmt.Errorf("invalid namespace %s: %s", ToNamespace, strings.Join(errs, ", "))
+	}
+
+	if outputName != arg {
+		err := checkOutputFile(outputName)
+		if err != nil {
+			return err
+		}
+	}
+
+	m, secrets, err := unsealSecrets(cmd, arg)
+	if err != nil {
+		return err
+	}
+
+	if ToName != "" && len(secrets) > 1 {
+		return fmt.Errorf("%s has %d sealed secrets, --to-name needs exactly one", arg, len(secrets))
+	}
+
+	if ToContext != "" {
+		useContext(ToContext)
+	}
+
+	key, err := getPublicKey(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("unable to get public key: %w", err)
+	}
+
+	for _, s := range secrets {
+		original := v1alpha1.SealedSecret{}
+		err = yaml.Unmarshal(m.documents[s.index], &original)
+		if err != nil {
+			return fmt.Errorf("unable to unmarshal secret: %v", err)
+		}
+
+		sealedSecret := s.sealedSecret.DeepCopy()
+		sealedSecret.Status = nil
+
+		ns := sealedSecret.Namespace
+		nsFromFile := ns == original.Namespace
+		if ToNamespace != "" {
+			ns = ToNamespace
+			nsFromFile = true
+			delete(sealedSecret.Annotations, NamespaceKey)
+		}
+
+		name := sealedSecret.Name
+		if ToName != "" {
+			name = ToName
+		}
+
+		scope := v1alpha1.SecretScope(s.sealedSecret)
+		sealedSecret.Spec.EncryptedData, err = encryptData(key, s, name, ns, scope)
+		if err != nil {
+			return err
+		}
+
+		digests, err := unsealedDigests(digestLabel(ns, name, scope), s)
+		if err != nil {
+			return err
+		}
+
+		err = setDigests(sealedSecret, digests)
+		if err != nil {
+			return err
+		}
+
+		sealedSecret.Name = name
+		sealedSecret.Namespace = ns
+		if sealedSecret.Spec.Template.Name != "" {
+			sealedSecret.Spec.Template.Name = name
+		}
+
+		if sealedSecret.Spec.Template.Namespace != "" {
+			sealedSecret.Spec.Template.Namespace = ns
+		}
+
+		m.documents[s.index], err = formatSealed(*sealedSecret, ns, nsFromFile, keepsTemplate(original))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Resealed '%s/%s' as '%s/%s'\n", s.sealedSecret.Namespace, s.sealedSecret.Name, ns, name)
+	}
+
+	data, err := m.bytes(fileFormat(outputName))
+	if err != nil {
+		return fmt.Errorf("unable to marshal %s: %v", outputName, err)
+	}
+
+	err = os.WriteFile(outputName, data, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write file: %v", err)
+	}
+
+	if !move {
+		fmt.Printf("Copied %s to %s\n", arg, outputName)
+		return nil
+	}
+
+	if outputName != arg {
+		err = os.Remove(arg)
+		if err != nil {
+			return fmt.Errorf("unable to remove %s: %v", arg, err)
+		}
+	}
+
+	fmt.Printf("Moved %s to %s\n", arg, outputName)
+	return nil
+}
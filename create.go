@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+var FromLiterals []string
+var FromFiles []string
+var FromEnvFiles []string
+var SecretType = string(corev1.SecretTypeOpaque)
+
+func Create(cmd *cobra.Command, args []string) error {
+	secret, err := newSecret(args[0], corev1.SecretType(SecretType))
+	if err != nil {
+		return err
+	}
+
+	for _, literal := range FromLiterals {
+		key, value, ok := strings.Cut(literal, "=")
+		if !ok {
+			return fmt.Errorf("invalid literal %q, expected key=value", literal)
+		}
+
+		err = addSecretKey(secret, key, []byte(value))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, source := range FromFiles {
+		err = addSecretFile(secret, source)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range FromEnvFiles {
+		err = addSecretEnvFile(secret, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return sealNewSecret(cmd, secret)
+}
+
+func newSecret(name string, secretType corev1.SecretType) (*corev1.Secret, error) {
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid secret name %s: %s", name, strings.Join(errs, ", "))
+	}
+
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: Namespace,
+		},
+		Type: secretType,
+		Data: make(map[string][]byte),
+	}, nil
+}
+
+// sealNewSecret seals a Secret built in memory to --output, which defaults to the name of the secret.
+func sealNewSecret(cmd *cobra.Command, secret *corev1.Secret) error {
+	outputName := OutputFile
+	if outputName == "" {
+		outputName = secret.Name + "." + string(fileFormat(""))
+	}
+
+	return sealNewSecretTo(cmd, secret, outputName)
+}
+
+// sealNewSecretTo seals a Secret held in memory through the same path as seal, so that the plaintext is
+// never written to disk.
+func sealNewSecretTo(cmd *cobra.Command, secret *corev1.Secret, outputName string) error {
+	if _, err := os.Stat(outputName); err == nil && !Force {
+		return newError(ExitFileExists, "output file %s already exists, use --force to overwrite", outputName)
+	}
+
+	doc, err := yaml.Marshal(secret)
+	if err != nil {
+		return fmt.Errorf("unable to marshal secret: %v", err)
+	}
+
+	return sealSource(cmd, secret.Name, outputName, &manifest{documents: [][]byte{doc}})
+}
+
+func addSecretKey(secret *corev1.Secret, key string, value []byte) error {
+	if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+		return fmt.Errorf("invalid key %s: %s", key, strings.Join(errs, ", "))
+	}
+
+	if _, ok := secret.Data[key]; ok {
+		return fmt.Errorf("key %s is given more than once", key)
+	}
+
+	secret.Data[key] = value
+	return nil
+}
+
+// addSecretFile adds a --from-file source, [key=]path. The key defaults to the file name, and every regular
+// file in a directory is added under its own name.
+func addSecretFile(secret *corev1.Secret, source string) error {
+	key, name, ok := strings.Cut(source, "=")
+	if !ok {
+		key, name = "", source
+	}
+
+	if !isDir(name) {
+		if key == "" {
+			key = filepath.Base(name)
+		}
+
+		data, err := readFile(name)
+		if err != nil {
+			return err
+		}
+
+		return addSecretKey(secret, key, data)
+	}
+
+	if key != "" {
+		return fmt.Errorf("cannot give a key for directory %s", name)
+	}
+
+	entries, err := os.ReadDir(name)
+	if err != nil {
+		return fmt.Errorf("unable to read directory %s: %v", name, err)
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		data, err := readFile(filepath.Join(name, entry.Name()))
+		if err != nil {
+			return err
+		}
+
+		err = addSecretKey(secret, entry.Name(), data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addSecretEnvFile adds the KEY=VALUE lines of an env file, skipping blank lines and comments. A line with
+// only a KEY takes its value from the environment.
+func addSecretEnvFile(secret *corev1.Secret, name string) error {
+	data, err := readFile(name)
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimLeft(scanner.Text(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			value, ok = os.LookupEnv(key)
+			if !ok {
+				continue
+			}
+		}
+
+		if errs := validation.IsEnvVarName(key); len(errs) > 0 {
+			return fmt.Errorf("%s line %d: invalid key %s: %s", name, n, key, strings.Join(errs, ", "))
+		}
+
+		err = addSecretKey(secret, key, []byte(value))
+		if err != nil {
+			return fmt.Errorf("%s line %d: %v", name, n, err)
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("unable to read %s: %v", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+	"sync"
+)
+
+// DigestsKey holds a keyed digest of the value of every encrypted key of a SealedSecret, so that a later seal
+// can tell which keys changed with only the controller certificate and the digest key.
+const DigestsKey = "sealedsecrets.hfox.me/digests"
+
+// minDigestKeySize is the shortest --digest-key accepted. Whoever holds the key can test guesses of a value
+// against its digest, so it has to be as secret as the values and too long to guess itself.
+const minDigestKeySize = 32
+
+// DigestKey is the file holding the key of the digests. Digests are only written and compared if it is set.
+var DigestKey string
+
+// the key is read once per run and shared by every file, which may be processed concurrently
+var digestKey []byte
+var digestKeyMu sync.Mutex
+
+// readDigestKey returns the key of --digest-key, or nil if digests are not used.
+func readDigestKey() ([]byte, error) {
+	digestKeyMu.Lock()
+	defer digestKeyMu.Unlock()
+
+	if DigestKey == "" || digestKey != nil {
+		return digestKey, nil
+	}
+
+	data, err := readFile(DigestKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key := bytes.TrimSpace(data)
+	if len(key) < minDigestKeySize {
+		return nil, fmt.Errorf("digest key %s must be at least %d bytes", DigestKey, minDigestKeySize)
+	}
+
+	digestKey = key
+	return digestKey, nil
+}
+
+// digestLabel identifies what a value is sealed for. Digests cover it and the key, so equal values in other
+// keys, secrets or scopes have unrelated digests, and a digest that no longer applies never matches.
+func digestLabel(ns string, name string, scope v1alpha1.SealingScope) string {
+	return ns + "/" + name + "/" + scope.String()
+}
+
+// valueDigest returns the HMAC-SHA256 under key of the value of k in label. Names and keys cannot hold a
+// NUL, which separates them from the value.
+func valueDigest(key []byte, label string, k string, value []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(label + "/" + k + "\x00"))
+	mac.Write(value)
+	return base64.RawStdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// sealedDigests returns the digests of a SealedSecret by key, or nil if it has none or they are unreadable.
+func sealedDigests(sealedSecret *v1alpha1.SealedSecret) map[string]string {
+	value, ok := sealedSecret.Annotations[DigestsKey]
+	if !ok {
+		return nil
+	}
+
+	var digests map[string]string
+	if err := json.Unmarshal([]byte(value), &digests); err != nil {
+		WarningLogger.Printf("ignoring unreadable digests of %s: %v", sealedSecret.Name, err)
+		return nil
+	}
+
+	return digests
+}
+
+// setDigests stores digests on a SealedSecret, or removes them if there are none, as when --digest-key is
+// not set.
+func setDigests(sealedSecret *v1alpha1.SealedSecret, digests map[string]string) error {
+	if len(digests) == 0 {
+		delete(sealedSecret.Annotations, DigestsKey)
+		if len(sealedSecret.Annotations) == 0 {
+			sealedSecret.Annotations = nil
+		}
+
+		return nil
+	}
+
+	value, err := json.Marshal(digests)
+	if err != nil {
+		return fmt.Errorf("unable to marshal digests: %v", err)
+	}
+
+	if sealedSecret.Annotations == nil {
+		sealedSecret.Annotations = make(map[string]string)
+	}
+
+	sealedSecret.Annotations[DigestsKey] = string(value)
+	return nil
+}
+
+// unsealedDigests returns the digests of the encrypted keys of a decrypted SealedSecret as sealed under label.
+func unsealedDigests(label string, s unsealedSecret) (map[string]string, error) {
+	key, err := readDigestKey()
+	if err != nil || key == nil {
+		return nil, err
+	}
+
+	digests := make(map[string]string, len(s.sealedSecret.Spec.EncryptedData))
+	for k := range s.sealedSecret.Spec.EncryptedData {
+		digests[k] = valueDigest(key, label, k, s.secret.Data[k])
+	}
+
+	return digests, nil
+}
+
+// secretDigests returns the digests of the keys sealed from sourceSecret and of the skipped keys of
+// originalSecret. Skipped keys are digested again if originalSecret was decrypted, and keep their digest if
+// it was only compared by digest.
+func secretDigests(label string, sourceSecret *corev1.Secret, skipped []string, originalSecret *unsealedSecret) (map[string]string, error) {
+	key, err := readDigestKey()
+	if err != nil || key == nil {
+		return nil, err
+	}
+
+	values := make(map[string][]byte, len(sourceSecret.Data)+len(sourceSecret.StringData)+len(skipped))
+	for k, v := range sourceSecret.Data {
+		values[k] = v
+	}
+
+	// stringData wins over data, as it does when the secret is sealed
+	for k, v := range sourceSecret.StringData {
+		values[k] = []byte(v)
+	}
+
+	digests := make(map[string]string, len(values))
+	for _, k := range skipped {
+		if originalSecret.secret != nil {
+			values[k] = originalSecret.secret.Data[k]
+		} else {
+			digests[k] = sealedDigests(originalSecret.sealedSecret)[k]
+		}
+	}
+
+	for k, v := range values {
+		digests[k] = valueDigest(key, label, k, v)
+	}
+
+	return digests, nil
+}
+
+// digestedSecrets reads the SealedSecrets in the file without decrypting them, for comparing by digest. It
+// returns a nil manifest if --digest-key is not set or any SealedSecret lacks the digest of one of its keys,
+// as the file then has to be decrypted to tell which keys changed.
+func digestedSecrets(name string) (*manifest, []unsealedSecret, error) {
+	key, err := readDigestKey()
+	if err != nil || key == nil {
+		return nil, nil, err
+	}
+
+	data, err := readFile(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, err := readManifest(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read documents from %s: %v", name, err)
+	}
+
+	var secrets []unsealedSecret
+	for i, doc := range m.documents {
+		kind, err := documentKind(doc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to unmarshal document %d of %s: %v", i+1, name, err)
+		}
+
+		if kind != "SealedSecret" {
+			continue
+		}
+
+		sealedSecret := &v1alpha1.SealedSecret{}
+		err = yaml.Unmarshal(doc, sealedSecret)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to unmarshal secret: %v", err)
+		}
+
+		digests := sealedDigests(sealedSecret)
+		for k := range sealedSecret.Spec.EncryptedData {
+			if _, ok := digests[k]; !ok {
+				return nil, nil, nil
+			}
+		}
+
+		ns, err := resolveNamespace(sealedSecret.ObjectMeta)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		sealedSecret.Namespace = ns
+		secrets = append(secrets, unsealedSecret{index: i, sealedSecret: sealedSecret})
+	}
+
+	if len(secrets) == 0 {
+		return nil, nil, nil
+	}
+
+	return m, secrets, nil
+}
+
+// skipDigested removes the keys of sourceSecret whose digest under label matches the one of originalSecret,
+// returning their names. Keys only in originalSecret are not returned, so they are dropped like keys removed
+// from the source when comparing by decrypting.
+func skipDigested(label string, sourceSecret *corev1.Secret, originalSecret *unsealedSecret) ([]string, error) {
+	key, err := readDigestKey()
+	if err != nil {
+		return nil, err
+	}
+
+	digests := sealedDigests(originalSecret.sealedSecret)
+	skipped := make([]string, 0, len(originalSecret.sealedSecret.Spec.EncryptedData))
+	for k := range originalSecret.sealedSecret.Spec.EncryptedData {
+		source, ok := sourceSecret.Data[k]
+		if s, inStringData := sourceSecret.StringData[k]; inStringData {
+			source, ok = []byte(s), true
+		}
+
+		if !ok || digests[k] == "" {
+			continue
+		}
+
+		if hmac.Equal([]byte(valueDigest(key, label, k, source)), []byte(digests[k])) {
+			delete(sourceSecret.StringData, k)
+			delete(sourceSecret.Data, k)
+			skipped = append(skipped, k)
+		}
+	}
+
+	return skipped, nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
+	"github.com/bitnami-labs/sealed-secrets/pkg/crypto"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"testing"
+	"time"
+)
+
+func setDigestKey(t *testing.T, key string) {
+	oldDigestKey, oldKey := DigestKey, digestKey
+	t.Cleanup(func() { DigestKey, digestKey = oldDigestKey, oldKey })
+	DigestKey, digestKey = "", nil
+	if key != "" {
+		digestKey = []byte(key)
+	}
+}
+
+// newDigestedSealedSecret returns a SealedSecret of a, b and c with the given digests.
+func newDigestedSealedSecret(t *testing.T, digests map[string]string) *v1alpha1.SealedSecret {
+	annotation, err := json.Marshal(digests)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &v1alpha1.SealedSecret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "db",
+			Namespace:   "app",
+			Annotations: map[string]string{DigestsKey: string(annotation)},
+		},
+		Spec: v1alpha1.SealedSecretSpec{
+			EncryptedData: map[string]string{"a": "enc-a", "b": "enc-b", "c": "enc-c"},
+		},
+	}
+}
+
+// setSealingCert makes getPublicKey return the key of a new certificate passed as --cert, returning the
+// private key to decrypt with.
+func setSealingCert(t *testing.T) *rsa.PrivateKey {
+	key, cert, err := crypto.GeneratePrivateKeyAndCert(2048, 365*24*time.Hour, "sealed-secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := filepath.Join(t.TempDir(), "cert.pem")
+	err = os.WriteFile(name, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldCertURL, oldPublicKey := CertURL, publicKey
+	t.Cleanup(func() { CertURL, publicKey = oldCertURL, oldPublicKey })
+	CertURL, publicKey = name, nil
+
+	return key
+}
+
+func TestValueDigest(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	digest := valueDigest(key, "app/db/strict", "password", []byte("hunter2"))
+
+	tests := []struct {
+		name  string
+		key   []byte
+		label string
+		k     string
+		value string
+	}{
+		{name: "other digest key", key: []byte("fedcba9876543210fedcba9876543210"), label: "app/db/strict", k: "password", value: "hunter2"},
+		{name: "other label", key: key, label: "app/db/namespace-wide", k: "password", value: "hunter2"},
+		{name: "other key", key: key, label: "app/db/strict", k: "token", value: "hunter2"},
+		{name: "other value", key: key, label: "app/db/strict", k: "password", value: "hunter3"},
+	}
+
+	for _, tt := range tests {
+		if valueDigest(tt.key, tt.label, tt.k, []byte(tt.value)) == digest {
+			t.Errorf("%s: digest did not change", tt.name)
+		}
+	}
+
+	if valueDigest(key, "app/db/strict", "password", []byte("hunter2")) != digest {
+		t.Error("digest is not deterministic")
+	}
+}
+
+func TestSkipDigested(t *testing.T) {
+	const label = "app/db/strict"
+	setDigestKey(t, "0123456789abcdef0123456789abcdef")
+
+	original := map[string]string{"a": "1", "b": "2", "c": "3"}
+	digests := make(map[string]string, len(original))
+	for k, v := range original {
+		digests[k] = valueDigest(digestKey, label, k, []byte(v))
+	}
+
+	tests := []struct {
+		name        string
+		source      corev1.Secret
+		digests     map[string]string
+		wantSkipped []string
+		wantData    map[string][]byte
+		wantString  map[string]string
+	}{
+		{
+			name:        "unchanged keys are skipped",
+			source:      corev1.Secret{Data: map[string][]byte{"a": []byte("1"), "b": []byte("new"), "c": []byte("3")}},
+			digests:     digests,
+			wantSkipped: []string{"a", "c"},
+			wantData:    map[string][]byte{"b": []byte("new")},
+		},
+		{
+			name:        "stringData is compared",
+			source:      corev1.Secret{StringData: map[string]string{"a": "1", "b": "new", "c": "3"}},
+			digests:     digests,
+			wantSkipped: []string{"a", "c"},
+			wantString:  map[string]string{"b": "new"},
+		},
+		{
+			name:        "keys removed from the source are dropped",
+			source:      corev1.Secret{Data: map[string][]byte{"a": []byte("1")}},
+			digests:     digests,
+			wantSkipped: []string{"a"},
+		},
+		{
+			name:        "new keys are sealed",
+			source:      corev1.Secret{Data: map[string][]byte{"a": []byte("1"), "b": []byte("2"), "c": []byte("3"), "d": []byte("4")}},
+			digests:     digests,
+			wantSkipped: []string{"a", "b", "c"},
+			wantData:    map[string][]byte{"d": []byte("4")},
+		},
+		{
+			name:     "keys without a digest are sealed",
+			source:   corev1.Secret{Data: map[string][]byte{"a": []byte("1")}},
+			digests:  map[string]string{"b": digests["b"]},
+			wantData: map[string][]byte{"a": []byte("1")},
+		},
+		{
+			name:     "digests of another label never match",
+			source:   corev1.Secret{Data: map[string][]byte{"a": []byte("1")}},
+			digests:  map[string]string{"a": valueDigest(digestKey, "app/db/cluster-wide", "a", []byte("1"))},
+			wantData: map[string][]byte{"a": []byte("1")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := tt.source.DeepCopy()
+			skipped, err := skipDigested(label, source, &unsealedSecret{sealedSecret: newDigestedSealedSecret(t, tt.digests)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sort.Strings(skipped)
+			if len(skipped) != len(tt.wantSkipped) || (len(skipped) > 0 && !reflect.DeepEqual(skipped, tt.wantSkipped)) {
+				t.Errorf("skipped = %v, want %v", skipped, tt.wantSkipped)
+			}
+
+			if len(source.Data) != len(tt.wantData) || (len(tt.wantData) > 0 && !reflect.DeepEqual(source.Data, tt.wantData)) {
+				t.Errorf("data = %v, want %v", source.Data, tt.wantData)
+			}
+
+			if len(source.StringData) != len(tt.wantString) || (len(tt.wantString) > 0 && !reflect.DeepEqual(source.StringData, tt.wantString)) {
+				t.Errorf("stringData = %v, want %v", source.StringData, tt.wantString)
+			}
+		})
+	}
+}
+
+func TestDigestsNeedKey(t *testing.T) {
+	setDigestKey(t, "")
+
+	digests, err := secretDigests("app/db/strict", &corev1.Secret{Data: map[string][]byte{"a": []byte("1")}}, nil, &unsealedSecret{})
+	if err != nil || digests != nil {
+		t.Errorf("secretDigests without --digest-key = %v, %v, want none", digests, err)
+	}
+
+	name := filepath.Join(t.TempDir(), "db.yaml")
+	err = os.WriteFile(name, []byte("apiVersion: bitnami.com/v1alpha1\nkind: SealedSecret\nmetadata:\n  name: db\n  namespace: app\nspec:\n  encryptedData:\n    a: AgBy3i4O\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, _, err := digestedSecrets(name)
+	if err != nil || m != nil {
+		t.Errorf("digestedSecrets without --digest-key = %v, %v, want none", m, err)
+	}
+}
+
+func TestSealByDigest(t *testing.T) {
+	setDigestKey(t, "0123456789abcdef0123456789abcdef")
+	privateKey := setSealingCert(t)
+
+	cmd := &cobra.Command{}
+	cmd.Flags().VarP(&Scope, "scope", "s", "sealing scope")
+	cmd.SetContext(context.Background())
+
+	output := filepath.Join(t.TempDir(), "db.yaml")
+	seal := func(t *testing.T, b string) v1alpha1.SealedSecret {
+		source, err := readManifest([]byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: db\n  namespace: app\n  annotations:\n    sealedsecrets.bitnami.com/namespace-wide: \"true\"\nstringData:\n  a: \"1\"\n  b: \"" + b + "\"\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = sealSource(cmd, "db.unsealed.yaml", output, source)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sealedSecret := v1alpha1.SealedSecret{}
+		err = yaml.Unmarshal(data, &sealedSecret)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return sealedSecret
+	}
+
+	sealed := seal(t, "2")
+	if scope := v1alpha1.SecretScope(&sealed); scope != v1alpha1.NamespaceWideScope {
+		t.Fatalf("sealed with %s scope, want namespace-wide", scope.String())
+	}
+
+	resealed := seal(t, "3")
+	if resealed.Spec.EncryptedData["a"] != sealed.Spec.EncryptedData["a"] {
+		t.Error("unchanged key a was encrypted again")
+	}
+
+	if resealed.Spec.EncryptedData["b"] == sealed.Spec.EncryptedData["b"] {
+		t.Error("changed key b was not encrypted again")
+	}
+
+	secret, err := decryptSealedSecret(&resealed, map[string]*rsa.PrivateKey{"test": privateKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]byte{"a": []byte("1"), "b": []byte("3")}
+	if !reflect.DeepEqual(secret.Data, want) {
+		t.Errorf("resealed data = %q, want %q", secret.Data, want)
+	}
+
+	digests := sealedDigests(&resealed)
+	for k, v := range want {
+		if digests[k] != valueDigest(digestKey, "app/db/namespace-wide", k, v) {
+			t.Errorf("digest of %s is not under the namespace-wide label", k)
+		}
+	}
+}
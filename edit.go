@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+func Edit(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return cmd.Help()
+	}
+
+	for _, arg := range args {
+		err := edit(cmd, arg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func edit(cmd *cobra.Command, arg string) error {
+	sealed, secrets, err := unsealSecrets(cmd, arg)
+	if err != nil {
+		return err
+	}
+
+	unsealed := &manifest{list: sealed.list, documents: append([][]byte(nil), sealed.documents...)}
+	for _, s := range secrets {
+		unsealed.documents[s.index], err = formatUnsealed(s.secret, s.sealedSecret)
+		if err != nil {
+			return err
+		}
+	}
+
+	original, err := unsealed.bytes(fileFormat(arg))
+	if err != nil {
+		return fmt.Errorf("unable to marshal unsealed secrets: %v", err)
+	}
+
+	dir, err := os.MkdirTemp("", "sealedsecrets-")
+	if err != nil {
+		return fmt.Errorf("unable to create temp dir: %v", err)
+	}
+
+	tempName := filepath.Join(dir, unsealOutputName(filepath.Base(arg)))
+	defer func() {
+		removeErr := shred(tempName)
+		if removeErr != nil {
+			ErrorLogger.Printf("unable to remove temp file: %v", removeErr)
+		}
+
+		_ = os.RemoveAll(dir)
+	}()
+
+	err = os.WriteFile(tempName, original, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to write to temp file: %v", err)
+	}
+
+	err = runEditor(tempName)
+	if err != nil {
+		return fmt.Errorf("editor failed, leaving %s unchanged: %v", arg, err)
+	}
+
+	edited, err := os.ReadFile(tempName)
+	if err != nil {
+		return fmt.Errorf("unable to read temp file: %v", err)
+	}
+
+	if bytes.Equal(original, edited) {
+		fmt.Printf("No changes made to %s\n", arg)
+		return nil
+	}
+
+	source, err := readManifest(edited)
+	if err != nil {
+		return fmt.Errorf("unable to read edited documents, leaving %s unchanged: %v", arg, err)
+	}
+
+	return sealManifest(cmd, arg, arg, source, sealed, secrets)
+}
+
+// runEditor opens name in $VISUAL or $EDITOR, falling back to vi.
+func runEditor(name string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+
+	if editor == "" {
+		editor = "vi"
+	}
+
+	parts := strings.Fields(editor)
+	c := exec.Command(parts[0], append(parts[1:], name)...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c.Run()
+}
+
+// shred overwrites name with zeros before removing it, so the plaintext does not linger on disk.
+func shred(name string) error {
+	info, err := os.Stat(name)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(make([]byte, info.Size()))
+	if err == nil {
+		err = f.Sync()
+	}
+
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(name)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// Exit codes, so that scripts can tell why a run failed.
+const (
+	ExitFailure               = 1
+	ExitNotFound              = 2
+	ExitNamespaceUndetermined = 3
+	ExitClusterUnreachable    = 4
+	ExitDecryptFailed         = 5
+	ExitFileExists            = 6
+	ExitLeaksFound            = 7
+	ExitDrift                 = 8
+)
+
+// Error is an error that makes the process exit with Code.
+type Error struct {
+	Code int
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func newError(code int, format string, args ...interface{}) error {
+	return &Error{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// exitCode returns the exit code for err, ExitFailure if it does not carry one.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+
+	return ExitFailure
+}
+
+// readFile is os.ReadFile, returning an error with ExitNotFound if the file does not exist.
+func readFile(name string) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, newError(ExitNotFound, "file %s does not exist", name)
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read file %s: %v", name, err)
+	}
+
+	return data, nil
+}
@@ -1,5 +1,7 @@
 package main
 
+import "github.com/spf13/cobra"
+
 var KubeConfig = "$HOME/.kube/config"
 var Context string
 var Namespace string
@@ -7,3 +9,38 @@ var Namespace string
 var Force bool
 
 var OutputFile string
+
+var Format FileFormat
+
+// addCertFlags adds the flags that choose the controller certificate to seal with, which is otherwise fetched
+// from source.
+func addCertFlags(c *cobra.Command, source string) {
+	c.PersistentFlags().StringVar(&CertURL, "cert", CertURL, "controller certificate to seal with (path, file:// or https:// URL), defaults to fetching it from "+source)
+	c.PersistentFlags().DurationVar(&CertMaxAge, "cert-max-age", CertMaxAge, "how long a cached controller certificate is used before fetching it again")
+}
+
+// addControllerFlags adds the flags that locate the sealed secrets controller.
+func addControllerFlags(c *cobra.Command) {
+	c.PersistentFlags().StringVar(&ControllerName, "controller-name", ControllerName, "name of the sealed secrets controller")
+	c.PersistentFlags().StringVar(&ControllerNamespace, "controller-namespace", ControllerNamespace, "namespace where the sealed secrets controller lives")
+}
+
+// addPrivateKeyFlag adds --private-key, for commands that unseal.
+func addPrivateKeyFlag(c *cobra.Command) {
+	c.PersistentFlags().StringArrayVarP(&PrivateKeys, "private-key", "k", PrivateKeys, "private key to unseal with instead of fetching the keys from the cluster, accepts PEM files or the controller key Secret YAML (repeatable)")
+}
+
+// addBatchFlags adds the flags of commands that process files with runBatch. keepGoing is false for commands
+// that always process every file.
+func addBatchFlags(c *cobra.Command, keepGoing bool) {
+	c.PersistentFlags().BoolVarP(&Recursive, "recursive", "R", Recursive, "process directories recursively")
+	c.PersistentFlags().IntVarP(&Jobs, "jobs", "j", Jobs, "number of files to process in parallel")
+	if keepGoing {
+		c.PersistentFlags().BoolVar(&KeepGoing, "keep-going", KeepGoing, "keep processing the remaining files after a file fails")
+	}
+}
+
+// addDigestKeyFlag adds --digest-key, for commands that write sealed files.
+func addDigestKeyFlag(c *cobra.Command) {
+	c.PersistentFlags().StringVar(&DigestKey, "digest-key", DigestKey, "file holding a key of at least 32 bytes to store keyed digests of the values with, which let later seals skip unchanged keys without the private keys. Anyone with the key and the sealed file can check guesses of a value, so keep it as secret as the values")
+}
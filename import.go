@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/template"
+)
+
+var ExcludeTypes = []string{string(corev1.SecretTypeServiceAccountToken)}
+var Adopt bool
+
+func Import(cmd *cobra.Command, args []string) error {
+	// without the template the controller would replace the labels and annotations of the adopted Secrets
+	if Adopt && !KeepTemplate {
+		return fmt.Errorf("--adopt needs --keep-template, so that the adopted secrets keep their labels and annotations")
+	}
+
+	layout, err := template.New("layout").Option("missingkey=error").Parse(Layout)
+	if err != nil {
+		return fmt.Errorf("invalid layout: %v", err)
+	}
+
+	ns, err := contextNamespace()
+	if err != nil {
+		return err
+	}
+
+	client, err := getKubeClient()
+	if err != nil {
+		return newError(ExitClusterUnreachable, "unable to get kubernetes client: %v", err)
+	}
+
+	list, err := client.clientset.CoreV1().Secrets(ns).List(cmd.Context(), metav1.ListOptions{LabelSelector: Selector})
+	if err != nil {
+		return newError(ExitClusterUnreachable, "unable to list secrets: %v", err)
+	}
+
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].Name < list.Items[j].Name
+	})
+
+	// every file is checked before anything is sealed, so that a clash does not leave a partial import
+	var secrets []*corev1.Secret
+	var names []string
+	owners := make(map[string]string, len(list.Items))
+	for i := range list.Items {
+		secret := &list.Items[i]
+		if reason := importSkipReason(secret); reason != "" {
+			fmt.Printf("Skipped '%s/%s': %s\n", secret.Namespace, secret.Name, reason)
+			continue
+		}
+
+		name, err := layoutOutputName(layout, secret)
+		if err != nil {
+			return err
+		}
+
+		if owner, ok := owners[name]; ok {
+			return fmt.Errorf("layout gives %s for both %s and %s/%s", name, owner, secret.Namespace, secret.Name)
+		}
+
+		if _, err := os.Stat(name); err == nil && !Force {
+			return newError(ExitFileExists, "file %s already exists, use --force to reseal the changed keys of '%s/%s' into it", name, secret.Namespace, secret.Name)
+		}
+
+		secrets = append(secrets, secret)
+		names = append(names, name)
+		owners[name] = secret.Namespace + "/" + secret.Name
+	}
+
+	if len(secrets) == 0 {
+		return newError(ExitNotFound, "no secrets to import in namespace %s", ns)
+	}
+
+	for i, secret := range secrets {
+		err = os.MkdirAll(filepath.Dir(names[i]), 0755)
+		if err != nil {
+			return fmt.Errorf("unable to create directory: %v", err)
+		}
+
+		err = sealNewSecretTo(cmd, importedSecret(secret), names[i])
+		if err != nil {
+			return err
+		}
+
+		if Adopt {
+			err = adoptSecret(cmd.Context(), client, secret)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Printf("Imported %d secrets into %s\n", len(secrets), OutputDir)
+	return nil
+}
+
+// importSkipReason returns why a Secret is not imported, or an empty string if it is. Secrets of an excluded
+// type, the sealing keys and Secrets that another object controls, such as those unsealed from a
+// SealedSecret, are skipped.
+func importSkipReason(secret *corev1.Secret) string {
+	for _, t := range ExcludeTypes {
+		if string(secret.Type) == t {
+			return "type " + t + " is excluded"
+		}
+	}
+
+	if _, ok := secret.Labels[KeyLabel]; ok {
+		return "sealing key of the controller"
+	}
+
+	if owner := metav1.GetControllerOf(secret); owner != nil && owner.Kind == "SealedSecret" {
+		return "already unsealed from a sealed secret"
+	} else if owner != nil {
+		return fmt.Sprintf("controlled by %s %s", owner.Kind, owner.Name)
+	}
+
+	return ""
+}
+
+// importedSecret returns a live Secret as it would be written by hand, without anything the server or
+// kubectl added.
+func importedSecret(secret *corev1.Secret) *corev1.Secret {
+	annotations := make(map[string]string, len(secret.Annotations))
+	for k, v := range secret.Annotations {
+		annotations[k] = v
+	}
+
+	v1alpha1.StripLastAppliedAnnotations(annotations)
+	delete(annotations, v1alpha1.SealedSecretManagedAnnotation)
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        secret.Name,
+			Namespace:   secret.Namespace,
+			Labels:      secret.Labels,
+			Annotations: annotations,
+		},
+		Immutable: secret.Immutable,
+		Type:      secret.Type,
+		Data:      secret.Data,
+	}
+}
+
+// adoptSecret annotates a live Secret as managed, so that the controller takes it over when its SealedSecret
+// is applied rather than refusing to overwrite it. The Secret is never deleted, so nothing loses it meanwhile.
+func adoptSecret(ctx context.Context, client *ClientConfig, secret *corev1.Secret) error {
+	if secret.Annotations[v1alpha1.SealedSecretManagedAnnotation] == "true" {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{v1alpha1.SealedSecretManagedAnnotation: "true"},
+		},
+	})
+
+	if err != nil {
+		return fmt.Errorf("unable to marshal patch: %v", err)
+	}
+
+	_, err = client.clientset.CoreV1().Secrets(secret.Namespace).Patch(ctx, secret.Name, types.MergePatchType, patch, metav1.PatchOptions{FieldManager: FieldManager})
+	if err != nil {
+		return newError(ExitClusterUnreachable, "unable to annotate secret %s/%s: %v", secret.Namespace, secret.Name, err)
+	}
+
+	fmt.Printf("Marked '%s/%s' for adoption by its sealed secret\n", secret.Namespace, secret.Name)
+	return nil
+}
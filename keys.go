@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"filippo.io/age"
+	"fmt"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"os"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+)
+
+var Recipients []string
+var RecipientsFiles []string
+var Identities []string
+var PassphraseFile string
+
+func KeysBackup(cmd *cobra.Command, args []string) error {
+	err := checkOutputFile(OutputFile)
+	if err != nil {
+		return err
+	}
+
+	recipients, err := backupRecipients()
+	if err != nil {
+		return err
+	}
+
+	secrets, err := getKeySecrets(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	data, err := keyList(secrets)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := ageEncrypt(recipients, data)
+	if err != nil {
+		return fmt.Errorf("unable to encrypt keys: %v", err)
+	}
+
+	status, err := writeOutput(encrypted)
+	if err != nil {
+		return fmt.Errorf("unable to write backup: %v", err)
+	}
+
+	fmt.Fprintf(status, "Backed up %d keys from %s\n", len(secrets), ControllerNamespace)
+	return nil
+}
+
+// keyList returns the key Secrets as a List, with only what is needed to recreate them.
+func keyList(secrets []corev1.Secret) ([]byte, error) {
+	list := corev1.List{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "List",
+		},
+	}
+
+	for _, secret := range secrets {
+		// only what is needed to recreate the key, the creationTimestamp is kept to restore them in order
+		secret.ObjectMeta = metav1.ObjectMeta{
+			Name:              secret.Name,
+			Namespace:         secret.Namespace,
+			Labels:            secret.Labels,
+			Annotations:       secret.Annotations,
+			CreationTimestamp: secret.CreationTimestamp,
+		}
+
+		raw, err := json.Marshal(secret)
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal secret %s: %v", secret.Name, err)
+		}
+
+		list.Items = append(list.Items, runtime.RawExtension{Raw: raw})
+	}
+
+	data, err := yaml.Marshal(list)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal keys: %v", err)
+	}
+
+	return data, nil
+}
+
+func checkOutputFile(name string) error {
+	if name == "" || Force {
+		return nil
+	}
+
+	if _, err := os.Stat(name); err == nil {
+		return newError(ExitFileExists, "file %s already exists, use --force to overwrite", name)
+	}
+
+	return nil
+}
+
+// writeOutput writes data to --output, readable only by the owner, or stdout if it is not set. It returns
+// where any messages should go, so that they never end up mixed into the output.
+func writeOutput(data []byte) (*os.File, error) {
+	if OutputFile == "" {
+		_, err := os.Stdout.Write(data)
+		return os.Stderr, err
+	}
+
+	return os.Stdout, os.WriteFile(OutputFile, data, 0600)
+}
+
+func KeysRestore(cmd *cobra.Command, args []string) error {
+	data, err := readFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	// the output of keys combine is not encrypted
+	plaintext := data
+	if bytes.HasPrefix(data, []byte(ageVersion+"\n")) {
+		identities, err := restoreIdentities()
+		if err != nil {
+			return err
+		}
+
+		plaintext, err = ageDecrypt(identities, data)
+		if err != nil {
+			return newError(ExitDecryptFailed, "unable to decrypt %s: %v", args[0], err)
+		}
+	}
+
+	m, err := readManifest(plaintext)
+	if err != nil {
+		return fmt.Errorf("unable to read keys from %s: %v", args[0], err)
+	}
+
+	secrets := make([]corev1.Secret, 0, len(m.documents))
+	for _, doc := range m.documents {
+		secret := corev1.Secret{}
+		err = yaml.Unmarshal(doc, &secret)
+		if err != nil {
+			return fmt.Errorf("unable to unmarshal secret: %v", err)
+		}
+
+		if secret.Kind == "Secret" {
+			secrets = append(secrets, secret)
+		}
+	}
+
+	if len(secrets) == 0 {
+		return newError(ExitNotFound, "no keys found in %s", args[0])
+	}
+
+	// the controller seals with the newest key, so they are created oldest first
+	sort.SliceStable(secrets, func(i, j int) bool {
+		return secrets[i].CreationTimestamp.Before(&secrets[j].CreationTimestamp)
+	})
+
+	for i := range secrets {
+		err = restoreKeySecret(cmd.Context(), &secrets[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Restored %d keys into %s, restart the controller to load them\n", len(secrets), ControllerNamespace)
+	return nil
+}
+
+// restoreKeySecret creates the key Secret in the controller namespace. An existing Secret holding the same key
+// is left alone, one holding a different key is only replaced with --force.
+func restoreKeySecret(ctx context.Context, secret *corev1.Secret) error {
+	client, err := getKubeClient()
+	if err != nil {
+		return newError(ExitClusterUnreachable, "unable to get kubernetes client: %v", err)
+	}
+
+	secret.Namespace = ControllerNamespace
+	secret.CreationTimestamp = metav1.Time{}
+	if secret.Labels == nil {
+		secret.Labels = make(map[string]string)
+	}
+
+	secret.Labels[KeyLabel] = "active"
+
+	secrets := client.clientset.CoreV1().Secrets(ControllerNamespace)
+	existing, err := secrets.Get(ctx, secret.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = secrets.Create(ctx, secret, metav1.CreateOptions{})
+		if err != nil {
+			return newError(ExitClusterUnreachable, "unable to create secret %s: %v", secret.Name, err)
+		}
+
+		fmt.Printf("Created %s/%s\n", ControllerNamespace, secret.Name)
+		return nil
+	} else if err != nil {
+		return newError(ExitClusterUnreachable, "unable to get secret %s: %v", secret.Name, err)
+	}
+
+	if bytes.Equal(existing.Data[corev1.TLSPrivateKeyKey], secret.Data[corev1.TLSPrivateKeyKey]) {
+		fmt.Printf("%s/%s already exists\n", ControllerNamespace, secret.Name)
+		return nil
+	}
+
+	if !Force {
+		return newError(ExitFileExists, "secret %s/%s already exists with a different key, use --force to replace it", ControllerNamespace, secret.Name)
+	}
+
+	existing.Data = secret.Data
+	existing.Type = secret.Type
+	if existing.Labels == nil {
+		existing.Labels = make(map[string]string)
+	}
+
+	existing.Labels[KeyLabel] = "active"
+
+	_, err = secrets.Update(ctx, existing, metav1.UpdateOptions{})
+	if err != nil {
+		return newError(ExitClusterUnreachable, "unable to update secret %s: %v", secret.Name, err)
+	}
+
+	fmt.Printf("Replaced %s/%s\n", ControllerNamespace, secret.Name)
+	return nil
+}
+
+// backupRecipients returns the --recipient keys, or a passphrase recipient if there are none.
+func backupRecipients() ([]age.Recipient, error) {
+	recipients, err := parseRecipients()
+	if err != nil || len(recipients) > 0 {
+		return recipients, err
+	}
+
+	passphrase, err := readPassphrase("Passphrase", true)
+	if err != nil {
+		return nil, err
+	}
+
+	recipient, err := agePassphraseRecipient(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return []age.Recipient{recipient}, nil
+}
+
+func parseRecipients() ([]age.Recipient, error) {
+	lines := append([]string{}, Recipients...)
+	for _, name := range RecipientsFiles {
+		data, err := readFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		lines = append(lines, keyFileLines(data)...)
+	}
+
+	recipients := make([]age.Recipient, 0, len(lines))
+	for _, line := range lines {
+		recipient, err := age.ParseX25519Recipient(line)
+		if err != nil {
+			return nil, err
+		}
+
+		recipients = append(recipients, recipient)
+	}
+
+	return recipients, nil
+}
+
+// restoreIdentities returns the --identity keys, or a passphrase identity if there are none.
+func restoreIdentities() ([]age.Identity, error) {
+	identities, err := parseIdentities()
+	if err != nil || len(identities) > 0 {
+		return identities, err
+	}
+
+	passphrase, err := readPassphrase("Passphrase", false)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := agePassphraseIdentity(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return []age.Identity{identity}, nil
+}
+
+func parseIdentities() ([]age.Identity, error) {
+	identities := make([]age.Identity, 0, len(Identities))
+	for _, name := range Identities {
+		data, err := readFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, line := range keyFileLines(data) {
+			identity, err := age.ParseX25519Identity(line)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+
+			identities = append(identities, identity)
+		}
+	}
+
+	return identities, nil
+}
+
+// keyFileLines returns the keys in an age recipients or identity file, skipping blank lines and comments.
+func keyFileLines(data []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+// readPassphrase reads the passphrase from --passphrase-file, or prompts for it on the terminal, twice if
+// confirm is set.
+func readPassphrase(prompt string, confirm bool) ([]byte, error) {
+	if PassphraseFile != "" {
+		data, err := readFile(PassphraseFile)
+		if err != nil {
+			return nil, err
+		}
+
+		passphrase := bytes.TrimRight(data, "\r\n")
+		if len(passphrase) == 0 {
+			return nil, fmt.Errorf("passphrase file %s is empty", PassphraseFile)
+		}
+
+		return passphrase, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, fmt.Errorf("no terminal to read the passphrase from, use --passphrase-file")
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: ", prompt)
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read passphrase: %v", err)
+	}
+
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("passphrase must not be empty")
+	}
+
+	if confirm {
+		fmt.Fprintf(os.Stderr, "%s (again): ", prompt)
+		again, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read passphrase: %v", err)
+		}
+
+		if !bytes.Equal(passphrase, again) {
+			return nil, fmt.Errorf("passphrases do not match")
+		}
+	}
+
+	return passphrase, nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"filippo.io/age"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"sigs.k8s.io/yaml"
+)
+
+var Shares int
+var Threshold int
+
+const DefaultSharePrefix = "sealing-keys"
+
+// keyShare is the content of a share file before it is encrypted for its custodian. Shares of the same split
+// have the same ID, and Digest is the SHA-256 of the key List, to detect shares that do not belong together.
+type keyShare struct {
+	ID        string `json:"id"`
+	Index     int    `json:"index"`
+	Threshold int    `json:"threshold"`
+	Shares    int    `json:"shares"`
+	Digest    string `json:"digest"`
+	Data      []byte `json:"data"`
+}
+
+func KeysSplit(cmd *cobra.Command, args []string) error {
+	recipients, err := parseRecipients()
+	if err != nil {
+		return err
+	}
+
+	if len(recipients) > 0 && len(recipients) != Shares {
+		return fmt.Errorf("one recipient per share is needed, got %d recipients for %d shares", len(recipients), Shares)
+	} else if len(recipients) == 0 && PassphraseFile != "" {
+		return fmt.Errorf("--passphrase-file would protect every share with the same passphrase, use --recipient or enter one per share")
+	}
+
+	prefix := OutputFile
+	if prefix == "" {
+		prefix = DefaultSharePrefix
+	}
+
+	names := make([]string, Shares)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s.%d.age", prefix, i+1)
+		err = checkOutputFile(names[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	secrets, err := getKeySecrets(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	data, err := keyList(secrets)
+	if err != nil {
+		return err
+	}
+
+	shares, err := splitKeys(data, Shares, Threshold)
+	if err != nil {
+		return err
+	}
+
+	for i := range shares {
+		share, err := yaml.Marshal(shares[i])
+		if err != nil {
+			return fmt.Errorf("unable to marshal share: %v", err)
+		}
+
+		var recipient age.Recipient
+		if len(recipients) > 0 {
+			recipient = recipients[i]
+		} else {
+			passphrase, err := readPassphrase(fmt.Sprintf("Passphrase for share %d of %d", i+1, Shares), true)
+			if err != nil {
+				return err
+			}
+
+			recipient, err = agePassphraseRecipient(passphrase)
+			if err != nil {
+				return err
+			}
+		}
+
+		encrypted, err := ageEncrypt([]age.Recipient{recipient}, share)
+		if err != nil {
+			return fmt.Errorf("unable to encrypt share: %v", err)
+		}
+
+		err = os.WriteFile(names[i], encrypted, 0600)
+		if err != nil {
+			return fmt.Errorf("unable to write file: %v", err)
+		}
+
+		fmt.Printf("Wrote share %d of %d to %s\n", i+1, Shares, names[i])
+	}
+
+	fmt.Printf("Split %d keys from %s, any %d of the %d shares recover them\n", len(secrets), ControllerNamespace, Threshold, Shares)
+	return nil
+}
+
+func KeysCombine(cmd *cobra.Command, args []string) error {
+	err := checkOutputFile(OutputFile)
+	if err != nil {
+		return err
+	}
+
+	identities, err := parseIdentities()
+	if err != nil {
+		return err
+	}
+
+	shares := make([]keyShare, 0, len(args))
+	for _, name := range args {
+		data, err := readFile(name)
+		if err != nil {
+			return err
+		}
+
+		shareIdentities := identities
+		if len(shareIdentities) == 0 {
+			passphrase, err := readPassphrase(fmt.Sprintf("Passphrase for %s", name), false)
+			if err != nil {
+				return err
+			}
+
+			identity, err := agePassphraseIdentity(passphrase)
+			if err != nil {
+				return err
+			}
+
+			shareIdentities = []age.Identity{identity}
+		}
+
+		plaintext, err := ageDecrypt(shareIdentities, data)
+		if err != nil {
+			return newError(ExitDecryptFailed, "unable to decrypt %s: %v", name, err)
+		}
+
+		share := keyShare{}
+		err = yaml.Unmarshal(plaintext, &share)
+		if err != nil {
+			return fmt.Errorf("unable to unmarshal share %s: %v", name, err)
+		}
+
+		if len(shares) > 0 && (share.ID != shares[0].ID || share.Digest != shares[0].Digest) {
+			return fmt.Errorf("share %s is not from the same split as %s", name, args[0])
+		}
+
+		shares = append(shares, share)
+	}
+
+	data, err := combineKeys(shares)
+	if err != nil {
+		return err
+	}
+
+	status, err := writeOutput(data)
+	if err != nil {
+		return fmt.Errorf("unable to write keys: %v", err)
+	}
+
+	fmt.Fprintf(status, "Combined %d of %d shares\n", len(shares), shares[0].Shares)
+	return nil
+}
+
+// splitKeys splits the key List data into n shares, any threshold of which recover it.
+func splitKeys(data []byte, n, threshold int) ([]keyShare, error) {
+	parts, err := shamirSplit(data, n, threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(data)
+	shares := make([]keyShare, n)
+	for i, part := range parts {
+		shares[i] = keyShare{
+			ID:        hex.EncodeToString(id),
+			Index:     i + 1,
+			Threshold: threshold,
+			Shares:    n,
+			Digest:    hex.EncodeToString(digest[:]),
+			Data:      part,
+		}
+	}
+
+	return shares, nil
+}
+
+// combineKeys recovers the key List from shares of the same split, checking it against their digest so that
+// a tampered share is detected rather than restored.
+func combineKeys(shares []keyShare) ([]byte, error) {
+	if len(shares) < shares[0].Threshold {
+		return nil, fmt.Errorf("%d shares are needed, got %d", shares[0].Threshold, len(shares))
+	}
+
+	xs := make([]byte, len(shares))
+	parts := make([][]byte, len(shares))
+	for i, share := range shares {
+		if share.Index < 1 || share.Index > share.Shares {
+			return nil, newError(ExitDecryptFailed, "invalid share index %d", share.Index)
+		}
+
+		xs[i] = byte(share.Index)
+		parts[i] = share.Data
+	}
+
+	data, err := shamirCombine(xs, parts)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(data)
+	if hex.EncodeToString(digest[:]) != shares[0].Digest {
+		return nil, newError(ExitDecryptFailed, "shares do not combine to the original keys")
+	}
+
+	return data, nil
+}
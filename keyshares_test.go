@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCombineKeys(t *testing.T) {
+	data := []byte("apiVersion: v1\nkind: List\nitems: []\n")
+	shares, err := splitKeys(data, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := shares[2]
+	tampered.Data = append([]byte{}, tampered.Data...)
+	tampered.Data[0] ^= 1
+
+	reindexed := shares[2]
+	reindexed.Index = 4
+
+	outOfRange := shares[2]
+	outOfRange.Index = 256
+
+	tests := []struct {
+		name    string
+		shares  []keyShare
+		wantErr bool
+	}{
+		{name: "threshold", shares: []keyShare{shares[0], shares[2], shares[4]}},
+		{name: "all", shares: shares},
+		{name: "any order", shares: []keyShare{shares[3], shares[1], shares[0]}},
+		{name: "too few", shares: []keyShare{shares[0], shares[1]}, wantErr: true},
+		{name: "duplicate", shares: []keyShare{shares[0], shares[1], shares[1]}, wantErr: true},
+		{name: "tampered data", shares: []keyShare{shares[0], shares[1], tampered}, wantErr: true},
+		{name: "tampered index", shares: []keyShare{shares[0], shares[1], reindexed}, wantErr: true},
+		{name: "index out of range", shares: []keyShare{shares[0], shares[1], outOfRange}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			combined, err := combineKeys(tt.shares)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !bytes.Equal(combined, data) {
+				t.Errorf("combined %q, want %q", combined, data)
+			}
+		})
+	}
+}
@@ -12,10 +12,12 @@ import (
 	"os"
 	"sigs.k8s.io/yaml"
 	"strings"
+	"sync"
 )
 
 var clientConfig *ClientConfig
 var clientConfigErr error
+var clientConfigMu sync.Mutex
 
 type ClientConfig struct {
 	context   string
@@ -25,10 +27,18 @@ type ClientConfig struct {
 }
 
 func (c *ClientConfig) ClientConfig() (*rest.Config, error) {
+	if c == nil {
+		return nil, fmt.Errorf("no kubernetes config available")
+	}
+
 	return c.client, nil
 }
 
 func (c *ClientConfig) Namespace() (string, bool, error) {
+	if c == nil {
+		return "", false, fmt.Errorf("no kubernetes config available")
+	}
+
 	ctxName := c.config.CurrentContext
 	if ctxName == "" {
 		return "", false, fmt.Errorf("no current context")
@@ -73,6 +83,9 @@ type NamedAuthInfo struct {
 }
 
 func getKubeClient() (*ClientConfig, error) {
+	clientConfigMu.Lock()
+	defer clientConfigMu.Unlock()
+
 	if clientConfig != nil || clientConfigErr != nil {
 		return clientConfig, clientConfigErr
 	}
@@ -81,6 +94,39 @@ func getKubeClient() (*ClientConfig, error) {
 	return clientConfig, clientConfigErr
 }
 
+// contextNamespace returns --namespace, or the namespace of the kube context if it is not set.
+func contextNamespace() (string, error) {
+	if Namespace != "" {
+		return Namespace, nil
+	}
+
+	client, err := getKubeClient()
+	if err != nil {
+		return "", newError(ExitClusterUnreachable, "unable to get kubernetes client: %v", err)
+	}
+
+	ns, _, err := client.Namespace()
+	if err != nil {
+		return "", newError(ExitNamespaceUndetermined, "unable to determine namespace, use --namespace: %v", err)
+	}
+
+	return ns, nil
+}
+
+// useContext switches to the named kube context for the rest of the run, dropping the client and the
+// controller certificate of the previous one.
+func useContext(name string) {
+	clientConfigMu.Lock()
+	Context = name
+	clientConfig = nil
+	clientConfigErr = nil
+	clientConfigMu.Unlock()
+
+	keysMu.Lock()
+	publicKey = nil
+	keysMu.Unlock()
+}
+
 func newKubeClient() (*ClientConfig, error) {
 	var restConfig *rest.Config
 	var err error
@@ -21,11 +21,17 @@ func main() {
 	logging.LogOutputs = "stdout:text"
 
 	err := cobrastarter.Run(cmd.CommandConfig{
-		ConfigFileName: "sealedsecrets",
+		ConfigFileName: ConfigFileName,
 		EnvPrefix:      "",
 		BaseCommand: &cobra.Command{
 			Use:   "sealedsecrets",
 			Short: "tool for working with sealed secrets",
+			// errors are printed below, and usage is only useful for flag errors, which happen before this runs
+			SilenceErrors: true,
+			PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+				cmd.SilenceUsage = true
+				return nil
+			},
 		},
 		DisableDefaultFlags: true,
 		RootFlags: func(cmd *cobra.Command) error {
@@ -42,12 +48,26 @@ func main() {
 		Commands: []cmd.CommandAdder{
 			unsealCommand,
 			sealCommand,
+			editCommand,
+			verifyCommand,
+			reencryptCommand,
+			certCommand,
+			keysCommand,
+			createCommand,
+			rescopeCommand,
+			copyCommand,
+			moveCommand,
+			scanCommand,
+			statusCommand,
+			applyCommand,
+			pullCommand,
+			importCommand,
 		},
 	})
 
 	if err != nil {
 		println(fmt.Sprintf("error: %v", err))
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
@@ -55,7 +75,7 @@ func unsealCommand(rootCmd *cobra.Command) (*cobra.Command, error) {
 	c := &cobra.Command{
 		Use:        "unseal",
 		Short:      "unseal a sealed secret",
-		Args:       cobra.MinimumNArgs(1),
+		Args:       cobra.ArbitraryArgs,
 		ArgAliases: []string{"secret_path"},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			return []string{toComplete}, cobra.ShellCompDirectiveNoFileComp
@@ -63,8 +83,13 @@ func unsealCommand(rootCmd *cobra.Command) (*cobra.Command, error) {
 		RunE: Unseal,
 	}
 
+	c.PersistentFlags().Var(&Format, "format", "file format (yaml, json), defaults to the format of the file extension")
 	c.PersistentFlags().BoolVarP(&Decode, "decode", "D", Decode, "force overwrite of existing files")
-	c.PersistentFlags().StringVarP(&OutputFile, "output", "o", OutputFile, "output file, defaults to modified input file if input ends with .yaml or no extension is provided")
+	c.PersistentFlags().StringVar(&FromCluster, "from-cluster", FromCluster, "unseal the SealedSecret [namespace/]name from the cluster instead of a file, to stdout unless --output is set")
+	c.PersistentFlags().BoolVar(&UnsealAll, "all", UnsealAll, "unseal every SealedSecret in --namespace from the cluster, to stdout unless --output is set")
+	c.PersistentFlags().StringVarP(&OutputFile, "output", "o", OutputFile, "output file, defaults to modified input file if input ends with .yaml or .json or no extension is provided")
+	addPrivateKeyFlag(c)
+	addBatchFlags(c, true)
 	return c, nil
 }
 
@@ -80,12 +105,389 @@ func sealCommand(rootCmd *cobra.Command) (*cobra.Command, error) {
 		RunE: Seal,
 	}
 
+	c.PersistentFlags().Var(&Format, "format", "file format (yaml, json), defaults to the format of the file extension")
 	c.PersistentFlags().BoolVarP(&Reseal, "reseal", "r", Reseal, "reseal the whole secret, not just the updated parts")
 	c.PersistentFlags().BoolVarP(&KeepTemplate, "keep-template", "t", KeepTemplate, "keep the template")
-	c.PersistentFlags().StringVarP(&OutputFile, "output", "o", OutputFile, "output file, defaults to modified input file if input ends with .unsealed.yaml or no extension is provided")
+	addDigestKeyFlag(c)
+	c.PersistentFlags().StringVarP(&OutputFile, "output", "o", OutputFile, "output file, defaults to modified input file if input ends with .unsealed.yaml or .unsealed.json or no extension is provided")
+	addBatchFlags(c, true)
+	c.PersistentFlags().VarP(&Scope, "scope", "s", "sealing scope (namespace, cluster, strict)")
+	addPrivateKeyFlag(c)
+	addCertFlags(c, "the cluster")
+	addControllerFlags(c)
+
+	return c, nil
+}
+
+func editCommand(rootCmd *cobra.Command) (*cobra.Command, error) {
+	c := &cobra.Command{
+		Use:        "edit",
+		Short:      "edit a sealed secret in $EDITOR and reseal the changed keys",
+		Args:       cobra.MinimumNArgs(1),
+		ArgAliases: []string{"secret_path"},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return []string{toComplete}, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: Edit,
+	}
+
+	c.PersistentFlags().Var(&Format, "format", "file format (yaml, json), defaults to the format of the file extension")
+	c.PersistentFlags().BoolVarP(&Decode, "decode", "D", Decode, "decode values into stringData while editing")
+	c.PersistentFlags().BoolVarP(&KeepTemplate, "keep-template", "t", KeepTemplate, "keep the template")
+	addDigestKeyFlag(c)
+	c.PersistentFlags().VarP(&Scope, "scope", "s", "sealing scope (namespace, cluster, strict)")
+	addPrivateKeyFlag(c)
+	addCertFlags(c, "the cluster")
+	addControllerFlags(c)
+
+	return c, nil
+}
+
+func verifyCommand(rootCmd *cobra.Command) (*cobra.Command, error) {
+	c := &cobra.Command{
+		Use:        "verify",
+		Short:      "verify that the controller can decrypt sealed secrets",
+		Args:       cobra.MinimumNArgs(1),
+		ArgAliases: []string{"secret_path"},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return []string{toComplete}, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: Verify,
+	}
+
+	addBatchFlags(c, false)
+	addControllerFlags(c)
+
+	return c, nil
+}
+
+func reencryptCommand(rootCmd *cobra.Command) (*cobra.Command, error) {
+	c := &cobra.Command{
+		Use:        "reencrypt",
+		Short:      "re-encrypt sealed secrets that were sealed with an older controller key",
+		Args:       cobra.MinimumNArgs(1),
+		ArgAliases: []string{"secret_path"},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return []string{toComplete}, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: Reencrypt,
+	}
+
+	c.PersistentFlags().Var(&Format, "format", "file format (yaml, json), defaults to the format of the file extension")
+	c.PersistentFlags().BoolVarP(&KeepTemplate, "keep-template", "t", KeepTemplate, "keep the template")
+	addBatchFlags(c, true)
+	addControllerFlags(c)
+
+	return c, nil
+}
+
+func certCommand(rootCmd *cobra.Command) (*cobra.Command, error) {
+	c := &cobra.Command{
+		Use:   "cert",
+		Short: "manage the controller certificate used for sealing",
+	}
+
+	addControllerFlags(c)
+
+	fetch := &cobra.Command{
+		Use:   "fetch",
+		Short: "fetch the controller certificate into the local cache",
+		Args:  cobra.NoArgs,
+		RunE:  CertFetch,
+	}
+
+	show := &cobra.Command{
+		Use:   "show",
+		Short: "show the certificate that seal would use",
+		Args:  cobra.NoArgs,
+		RunE:  CertShow,
+	}
+
+	show.Flags().StringVar(&CertURL, "cert", CertURL, "controller certificate to show (path, file:// or https:// URL), defaults to the cached certificate")
+	show.Flags().DurationVar(&CertMaxAge, "cert-max-age", CertMaxAge, "how long a cached controller certificate is used before fetching it again")
+
+	c.AddCommand(fetch, show)
+	return c, nil
+}
+
+func keysCommand(rootCmd *cobra.Command) (*cobra.Command, error) {
+	c := &cobra.Command{
+		Use:   "keys",
+		Short: "back up and restore the controller sealing keys",
+	}
+
+	addControllerFlags(c)
+	c.PersistentFlags().StringVar(&PassphraseFile, "passphrase-file", PassphraseFile, "file holding the passphrase, prompts for it if not specified")
+
+	backup := &cobra.Command{
+		Use:   "backup",
+		Short: "write the sealing keys to an encrypted archive, readable by age",
+		Args:  cobra.NoArgs,
+		RunE:  KeysBackup,
+	}
+
+	backup.Flags().StringVarP(&OutputFile, "output", "o", OutputFile, "output file, defaults to stdout")
+	backup.Flags().StringArrayVarP(&Recipients, "recipient", "r", Recipients, "age recipient (age1...) to encrypt to instead of a passphrase (repeatable)")
+	backup.Flags().StringArrayVar(&RecipientsFiles, "recipients-file", RecipientsFiles, "file with age recipients, one per line (repeatable)")
+
+	restore := &cobra.Command{
+		Use:        "restore",
+		Short:      "recreate the sealing keys from an encrypted archive or the output of keys combine",
+		Args:       cobra.ExactArgs(1),
+		ArgAliases: []string{"archive_path"},
+		RunE:       KeysRestore,
+	}
+
+	restore.Flags().StringArrayVarP(&Identities, "identity", "i", Identities, "age identity file (AGE-SECRET-KEY-1...) to decrypt with instead of a passphrase (repeatable)")
+
+	split := &cobra.Command{
+		Use:   "split",
+		Short: "split the sealing keys into encrypted shares, any --threshold of which recover them",
+		Args:  cobra.NoArgs,
+		RunE:  KeysSplit,
+	}
+
+	split.Flags().IntVar(&Shares, "shares", Shares, "number of shares to write")
+	split.Flags().IntVar(&Threshold, "threshold", Threshold, "number of shares needed to recover the keys")
+	split.Flags().StringVarP(&OutputFile, "output", "o", OutputFile, "prefix of the share files, which are named <prefix>.<n>.age, defaults to "+DefaultSharePrefix)
+	split.Flags().StringArrayVarP(&Recipients, "recipient", "r", Recipients, "age recipient (age1...) to encrypt each share to, in order, instead of a passphrase per share (repeatable)")
+	split.Flags().StringArrayVar(&RecipientsFiles, "recipients-file", RecipientsFiles, "file with age recipients, one per line (repeatable)")
+	_ = split.MarkFlagRequired("shares")
+	_ = split.MarkFlagRequired("threshold")
+
+	combine := &cobra.Command{
+		Use:        "combine",
+		Short:      "recover the sealing keys from shares, for unseal --private-key or keys restore",
+		Args:       cobra.MinimumNArgs(1),
+		ArgAliases: []string{"share_path"},
+		RunE:       KeysCombine,
+	}
+
+	combine.Flags().StringVarP(&OutputFile, "output", "o", OutputFile, "output file, defaults to stdout")
+	combine.Flags().StringArrayVarP(&Identities, "identity", "i", Identities, "age identity file (AGE-SECRET-KEY-1...) to decrypt the shares with instead of a passphrase per share (repeatable)")
+
+	c.AddCommand(backup, restore, split, combine)
+	return c, nil
+}
+
+func createCommand(rootCmd *cobra.Command) (*cobra.Command, error) {
+	c := &cobra.Command{
+		Use:        "create",
+		Short:      "create a sealed secret from literals, files and env files",
+		Args:       cobra.ExactArgs(1),
+		ArgAliases: []string{"name"},
+		RunE:       Create,
+	}
+
+	c.Flags().StringArrayVar(&FromLiterals, "from-literal", FromLiterals, "key and literal value to add, key=value (repeatable)")
+	c.Flags().StringArrayVar(&FromFiles, "from-file", FromFiles, "file to add, [key=]path, the key defaults to the file name, directories add every file (repeatable)")
+	c.Flags().StringArrayVar(&FromEnvFiles, "from-env-file", FromEnvFiles, "env file of KEY=VALUE lines to add (repeatable)")
+	c.Flags().StringVar(&SecretType, "type", SecretType, "type of the secret")
+
+	c.PersistentFlags().StringVarP(&OutputFile, "output", "o", OutputFile, "output file, defaults to the secret name")
+	c.PersistentFlags().Var(&Format, "format", "file format (yaml, json), defaults to the format of the output file extension")
+	c.PersistentFlags().BoolVarP(&Reseal, "reseal", "r", Reseal, "reseal the whole secret when the output exists, not just the updated parts")
+	c.PersistentFlags().BoolVarP(&KeepTemplate, "keep-template", "t", KeepTemplate, "keep the template")
+	addDigestKeyFlag(c)
+	c.PersistentFlags().VarP(&Scope, "scope", "s", "sealing scope (namespace, cluster, strict)")
+	addPrivateKeyFlag(c)
+	addCertFlags(c, "the cluster")
+	addControllerFlags(c)
+
+	createTLS := &cobra.Command{
+		Use:        "tls",
+		Short:      "create a sealed kubernetes.io/tls secret from a certificate and key",
+		Args:       cobra.ExactArgs(1),
+		ArgAliases: []string{"name"},
+		RunE:       CreateTLS,
+	}
+
+	// --cert is the certificate of the secret here, so the controller certificate has its own flag
+	createTLS.Flags().StringVar(&TLSCertFile, "cert", TLSCertFile, "PEM certificate (chain) to add as tls.crt")
+	createTLS.Flags().StringVar(&TLSKeyFile, "key", TLSKeyFile, "PEM private key to add as tls.key")
+	createTLS.Flags().StringVar(&CertURL, "controller-cert", CertURL, "controller certificate to seal with (path, file:// or https:// URL), defaults to fetching it from the cluster")
+	_ = createTLS.MarkFlagRequired("cert")
+	_ = createTLS.MarkFlagRequired("key")
+
+	createDockerRegistry := &cobra.Command{
+		Use:        "docker-registry",
+		Short:      "create a sealed kubernetes.io/dockerconfigjson secret for pulling images",
+		Args:       cobra.ExactArgs(1),
+		ArgAliases: []string{"name"},
+		RunE:       CreateDockerRegistry,
+	}
+
+	createDockerRegistry.Flags().StringVar(&RegistryServer, "server", RegistryServer, "registry server")
+	createDockerRegistry.Flags().StringVar(&Username, "username", Username, "registry username")
+	createDockerRegistry.Flags().StringVar(&Password, "password", Password, "registry password")
+	createDockerRegistry.Flags().StringVar(&Email, "email", Email, "registry email")
+	_ = createDockerRegistry.MarkFlagRequired("username")
+	_ = createDockerRegistry.MarkFlagRequired("password")
+
+	createBasicAuth := &cobra.Command{
+		Use:        "basic-auth",
+		Short:      "create a sealed kubernetes.io/basic-auth secret",
+		Args:       cobra.ExactArgs(1),
+		ArgAliases: []string{"name"},
+		RunE:       CreateBasicAuth,
+	}
+
+	createBasicAuth.Flags().StringVar(&Username, "username", Username, "username to add")
+	createBasicAuth.Flags().StringVar(&Password, "password", Password, "password to add")
+
+	c.AddCommand(createTLS, createDockerRegistry, createBasicAuth)
+	return c, nil
+}
+
+func rescopeCommand(rootCmd *cobra.Command) (*cobra.Command, error) {
+	c := &cobra.Command{
+		Use:        "rescope",
+		Short:      "reseal sealed secrets under a different sealing scope",
+		Args:       cobra.MinimumNArgs(1),
+		ArgAliases: []string{"secret_path"},
+		RunE:       Rescope,
+	}
+
+	c.PersistentFlags().VarP(&Scope, "scope", "s", "sealing scope to reseal under (namespace, cluster, strict)")
+	c.PersistentFlags().BoolVarP(&KeepTemplate, "keep-template", "t", KeepTemplate, "keep the template")
+	addDigestKeyFlag(c)
+	addBatchFlags(c, true)
+	addPrivateKeyFlag(c)
+	addCertFlags(c, "the cluster")
+	addControllerFlags(c)
+	_ = c.MarkPersistentFlagRequired("scope")
+
+	return c, nil
+}
+
+func copyCommand(rootCmd *cobra.Command) (*cobra.Command, error) {
+	c := &cobra.Command{
+		Use:        "copy",
+		Short:      "copy a sealed secret to another name, namespace or cluster",
+		Args:       cobra.ExactArgs(1),
+		ArgAliases: []string{"secret_path"},
+		RunE:       Copy,
+	}
+
+	c.PersistentFlags().StringVar(&ToNamespace, "to-namespace", ToNamespace, "namespace to reseal the secret for")
+	c.PersistentFlags().StringVar(&ToName, "to-name", ToName, "name to reseal the secret for")
+	c.PersistentFlags().StringVar(&ToContext, "to-context", ToContext, "kube context of the cluster to reseal the secret for, defaults to --context")
+	c.PersistentFlags().StringVarP(&OutputFile, "output", "o", OutputFile, "output file")
+	c.PersistentFlags().Var(&Format, "format", "file format (yaml, json), defaults to the format of the output file extension")
+	c.PersistentFlags().BoolVarP(&KeepTemplate, "keep-template", "t", KeepTemplate, "keep the template")
+	addDigestKeyFlag(c)
+	addPrivateKeyFlag(c)
+	addCertFlags(c, "the --to-context cluster")
+	addControllerFlags(c)
+
+	return c, nil
+}
+
+func moveCommand(rootCmd *cobra.Command) (*cobra.Command, error) {
+	c := &cobra.Command{
+		Use:        "move",
+		Short:      "move a sealed secret to another name, namespace or cluster",
+		Args:       cobra.ExactArgs(1),
+		ArgAliases: []string{"secret_path"},
+		RunE:       Move,
+	}
+
+	c.PersistentFlags().StringVar(&ToNamespace, "to-namespace", ToNamespace, "namespace to reseal the secret for")
+	c.PersistentFlags().StringVar(&ToName, "to-name", ToName, "name to reseal the secret for")
+	c.PersistentFlags().StringVar(&ToContext, "to-context", ToContext, "kube context of the cluster to reseal the secret for, defaults to --context")
+	c.PersistentFlags().StringVarP(&OutputFile, "output", "o", OutputFile, "output file, defaults to rewriting the input file, which is removed otherwise")
+	c.PersistentFlags().Var(&Format, "format", "file format (yaml, json), defaults to the format of the output file extension")
+	c.PersistentFlags().BoolVarP(&KeepTemplate, "keep-template", "t", KeepTemplate, "keep the template")
+	addDigestKeyFlag(c)
+	addPrivateKeyFlag(c)
+	addCertFlags(c, "the --to-context cluster")
+	addControllerFlags(c)
+
+	return c, nil
+}
+
+func scanCommand(rootCmd *cobra.Command) (*cobra.Command, error) {
+	c := &cobra.Command{
+		Use:        "scan",
+		Short:      "scan a tree or the staged git changes for unsealed secrets",
+		Long:       "scan a tree or the staged git changes for unsealed files, plain Secret manifests, stringData blocks and high-entropy values, exiting with 7 if any are found",
+		ArgAliases: []string{"path"},
+		RunE:       Scan,
+	}
+
+	c.PersistentFlags().BoolVar(&Staged, "staged", Staged, "scan the files staged in the git index, as a pre-commit hook")
+	c.PersistentFlags().BoolVar(&InstallHook, "install-hook", InstallHook, "install a git pre-commit hook that runs scan --staged")
+	c.PersistentFlags().StringVar(&IgnoreFile, "ignore-file", IgnoreFile, "file of path patterns to skip, like .gitignore")
+
+	return c, nil
+}
+
+func statusCommand(rootCmd *cobra.Command) (*cobra.Command, error) {
+	c := &cobra.Command{
+		Use:        "status",
+		Short:      "compare the sealed secrets in a directory with those in the cluster",
+		Long:       "compare the sealed secrets in a directory with those in the cluster, listing those only in one of them or whose encrypted data or template differ, exiting with 8 if any do",
+		ArgAliases: []string{"dir"},
+		RunE:       Status,
+	}
+
+	return c, nil
+}
+
+func applyCommand(rootCmd *cobra.Command) (*cobra.Command, error) {
+	c := &cobra.Command{
+		Use:        "apply",
+		Short:      "server-side apply sealed secrets and wait for the controller to unseal them",
+		Args:       cobra.MinimumNArgs(1),
+		ArgAliases: []string{"secret_path"},
+		RunE:       Apply,
+	}
+
+	c.PersistentFlags().DurationVar(&Timeout, "timeout", Timeout, "how long to wait for the controller to unseal each file, 0 to not wait")
+	c.PersistentFlags().BoolVar(&ForceConflicts, "force-conflicts", ForceConflicts, "take over fields of the sealed secrets managed by another tool, like kubectl apply --server-side --force-conflicts")
+	addBatchFlags(c, true)
+
+	return c, nil
+}
+
+func pullCommand(rootCmd *cobra.Command) (*cobra.Command, error) {
+	c := &cobra.Command{
+		Use:   "pull",
+		Short: "write the sealed secrets in the cluster to local files, one per object",
+		Args:  cobra.NoArgs,
+		RunE:  Pull,
+	}
+
+	c.PersistentFlags().BoolVarP(&AllNamespaces, "all-namespaces", "A", AllNamespaces, "pull from every namespace instead of --namespace or the namespace of the context")
+	c.PersistentFlags().StringVarP(&Selector, "selector", "l", Selector, "label selector to filter the sealed secrets by")
+	c.PersistentFlags().StringVar(&OutputDir, "output-dir", OutputDir, "directory to write the files to")
+	c.PersistentFlags().StringVar(&Layout, "layout", Layout, "Go template of the file path below --output-dir, without the extension, executed on each SealedSecret")
+	c.PersistentFlags().Var(&Format, "format", "file format (yaml, json), defaults to yaml")
+
+	return c, nil
+}
+
+func importCommand(rootCmd *cobra.Command) (*cobra.Command, error) {
+	c := &cobra.Command{
+		Use:   "import",
+		Short: "seal the plain secrets in the cluster to local files, one per secret",
+		Args:  cobra.NoArgs,
+		RunE:  Import,
+	}
+
+	c.PersistentFlags().StringVarP(&Selector, "selector", "l", Selector, "label selector to filter the secrets by")
+	c.PersistentFlags().StringArrayVar(&ExcludeTypes, "exclude-type", ExcludeTypes, "type of secret not to import (repeatable)")
+	c.PersistentFlags().BoolVar(&Adopt, "adopt", Adopt, "annotate each imported secret so the controller takes it over when its sealed secret is applied, needs --keep-template")
+	c.PersistentFlags().StringVar(&OutputDir, "output-dir", OutputDir, "directory to write the files to")
+	c.PersistentFlags().StringVar(&Layout, "layout", Layout, "Go template of the file path below --output-dir, without the extension, executed on each Secret")
+	c.PersistentFlags().Var(&Format, "format", "file format (yaml, json), defaults to yaml")
+	c.PersistentFlags().BoolVarP(&Reseal, "reseal", "r", Reseal, "reseal the whole secret when the file exists, not just the updated parts")
+	c.PersistentFlags().BoolVarP(&KeepTemplate, "keep-template", "t", KeepTemplate, "keep the template, with the labels and annotations of the secret")
+	addDigestKeyFlag(c)
 	c.PersistentFlags().VarP(&Scope, "scope", "s", "sealing scope (namespace, cluster, strict)")
-	c.PersistentFlags().StringVar(&ControllerName, "controller-name", ControllerName, "name of the sealed secrets controller")
-	c.PersistentFlags().StringVar(&ControllerNamespace, "controller-namespace", ControllerNamespace, "namespace where the sealed secrets controller lives")
+	addPrivateKeyFlag(c)
+	addCertFlags(c, "the cluster")
+	addControllerFlags(c)
 
 	return c, nil
 }
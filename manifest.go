@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+// FileFormat is the format files are written in, yaml or json. If it is not set with --format, it is
+// detected from the file name.
+type FileFormat string
+
+const (
+	FormatYAML FileFormat = "yaml"
+	FormatJSON FileFormat = "json"
+)
+
+func (f *FileFormat) String() string {
+	return string(*f)
+}
+
+func (f *FileFormat) Set(s string) error {
+	switch FileFormat(s) {
+	case FormatYAML, FormatJSON:
+		*f = FileFormat(s)
+		return nil
+	default:
+		return fmt.Errorf("must be yaml or json")
+	}
+}
+
+func (f *FileFormat) Type() string {
+	return "format"
+}
+
+// fileFormat returns --format if set, otherwise the format of name from its extension.
+func fileFormat(name string) FileFormat {
+	if Format != "" {
+		return Format
+	}
+
+	if strings.HasSuffix(name, ".json") {
+		return FormatJSON
+	}
+
+	return FormatYAML
+}
+
+// manifest is the content of a file holding one or more documents, either separated by --- or as the
+// items of a v1 List. Documents are kept as raw bytes so that anything we don't touch is written back as-is.
+type manifest struct {
+	list      bool
+	documents [][]byte
+}
+
+func readManifest(data []byte) (*manifest, error) {
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+
+	m := &manifest{}
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		m.documents = append(m.documents, doc)
+	}
+
+	if len(m.documents) == 1 {
+		kind, err := documentKind(m.documents[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if kind == "List" {
+			list := corev1.List{}
+			err = yaml.Unmarshal(m.documents[0], &list)
+			if err != nil {
+				return nil, fmt.Errorf("unable to unmarshal list: %v", err)
+			}
+
+			m.list = true
+			m.documents = make([][]byte, 0, len(list.Items))
+			for _, item := range list.Items {
+				m.documents = append(m.documents, item.Raw)
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// bytes returns the documents in format. As JSON has no multi-document form, several documents are written
+// as a List, like the manifests generated by jsonnet.
+func (m *manifest) bytes(format FileFormat) ([]byte, error) {
+	if format == FormatJSON {
+		return m.jsonBytes()
+	}
+
+	if m.list {
+		list := corev1.List{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: corev1.SchemeGroupVersion.String(),
+				Kind:       "List",
+			},
+			Items: make([]runtime.RawExtension, 0, len(m.documents)),
+		}
+
+		for _, doc := range m.documents {
+			raw, err := yaml.YAMLToJSON(doc)
+			if err != nil {
+				return nil, err
+			}
+
+			list.Items = append(list.Items, runtime.RawExtension{Raw: raw})
+		}
+
+		return yaml.Marshal(list)
+	}
+
+	documents := make([][]byte, 0, len(m.documents))
+	for _, doc := range m.documents {
+		// documents read from a JSON file are converted, the rest are kept as they are
+		if trimmed := bytes.TrimSpace(doc); len(trimmed) > 0 && trimmed[0] == '{' {
+			converted, err := yaml.JSONToYAML(doc)
+			if err != nil {
+				return nil, err
+			}
+
+			doc = converted
+		}
+
+		documents = append(documents, doc)
+	}
+
+	return bytes.Join(documents, []byte("---\n")), nil
+}
+
+func (m *manifest) jsonBytes() ([]byte, error) {
+	var data []byte
+	var err error
+	if !m.list && len(m.documents) == 1 {
+		data, err = yaml.YAMLToJSON(m.documents[0])
+	} else {
+		list := corev1.List{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: corev1.SchemeGroupVersion.String(),
+				Kind:       "List",
+			},
+			Items: make([]runtime.RawExtension, 0, len(m.documents)),
+		}
+
+		for _, doc := range m.documents {
+			raw, err := yaml.YAMLToJSON(doc)
+			if err != nil {
+				return nil, err
+			}
+
+			// documents holding only comments have no JSON form
+			if string(raw) != "null" {
+				list.Items = append(list.Items, runtime.RawExtension{Raw: raw})
+			}
+		}
+
+		data, err = json.Marshal(list)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	out := &bytes.Buffer{}
+	err = json.Indent(out, data, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// documentKind returns the kind of a document, or an empty string if it has none (e.g. it only holds comments).
+func documentKind(doc []byte) (string, error) {
+	typeMeta := metav1.TypeMeta{}
+	err := yaml.Unmarshal(doc, &typeMeta)
+	if err != nil {
+		return "", err
+	}
+
+	return typeMeta.Kind, nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFileFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		format FileFormat
+		file   string
+		want   FileFormat
+	}{
+		{name: "yaml extension", file: "secret.yaml", want: FormatYAML},
+		{name: "json extension", file: "secret.json", want: FormatJSON},
+		{name: "no extension", file: "secret", want: FormatYAML},
+		{name: "stdout", file: "", want: FormatYAML},
+		{name: "--format wins", format: FormatYAML, file: "secret.json", want: FormatYAML},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := Format
+			t.Cleanup(func() { Format = old })
+			Format = tt.format
+
+			if got := fileFormat(tt.file); got != tt.want {
+				t.Errorf("fileFormat(%q) = %s, want %s", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileFormatSet(t *testing.T) {
+	for _, s := range []string{"yaml", "json"} {
+		var f FileFormat
+		if err := f.Set(s); err != nil || f.String() != s {
+			t.Errorf("Set(%q) = %v, format %s", s, err, f.String())
+		}
+	}
+
+	var f FileFormat
+	if err := f.Set("yml"); err == nil {
+		t.Error("Set(\"yml\") succeeded")
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		wantList bool
+		want     []string
+	}{
+		{
+			name: "single document",
+			data: "kind: Secret\n",
+			want: []string{"kind: Secret\n"},
+		},
+		{
+			name: "documents separated by ---",
+			data: "kind: Secret\n---\n# comment\nkind: ConfigMap\n",
+			want: []string{"kind: Secret\n", "# comment\nkind: ConfigMap\n"},
+		},
+		{
+			name:     "yaml list",
+			data:     "apiVersion: v1\nkind: List\nitems:\n- kind: Secret\n- kind: ConfigMap\n",
+			wantList: true,
+			want:     []string{`{"kind":"Secret"}`, `{"kind":"ConfigMap"}`},
+		},
+		{
+			name:     "json list",
+			data:     `{"apiVersion": "v1", "kind": "List", "items": [{"kind": "Secret"}]}`,
+			wantList: true,
+			want:     []string{`{"kind":"Secret"}`},
+		},
+		{
+			name: "json object",
+			data: `{"kind": "Secret"}`,
+			want: []string{"{\"kind\": \"Secret\"}\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := readManifest([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if m.list != tt.wantList {
+				t.Errorf("list = %v, want %v", m.list, tt.wantList)
+			}
+
+			got := make([]string, len(m.documents))
+			for i, doc := range m.documents {
+				got[i] = string(doc)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("documents = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManifestBytes(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format FileFormat
+		want   string
+	}{
+		{
+			name:   "yaml documents are kept as they are",
+			data:   "# comment\nkind: Secret\n---\nkind: ConfigMap # inline\n",
+			format: FormatYAML,
+			want:   "# comment\nkind: Secret\n---\nkind: ConfigMap # inline\n",
+		},
+		{
+			name:   "yaml list",
+			data:   "apiVersion: v1\nkind: List\nitems:\n- kind: Secret\n",
+			format: FormatYAML,
+			want:   "apiVersion: v1\nitems:\n- kind: Secret\nkind: List\nmetadata: {}\n",
+		},
+		{
+			name:   "json file to yaml",
+			data:   `{"kind": "Secret"}`,
+			format: FormatYAML,
+			want:   "kind: Secret\n",
+		},
+		{
+			name:   "single document to json",
+			data:   "kind: Secret\n",
+			format: FormatJSON,
+			want:   "{\n  \"kind\": \"Secret\"\n}\n",
+		},
+		{
+			name:   "several documents to json are a list",
+			data:   "kind: Secret\n---\n# only a comment\n---\nkind: ConfigMap\n",
+			format: FormatJSON,
+			want: `{
+  "kind": "List",
+  "apiVersion": "v1",
+  "metadata": {},
+  "items": [
+    {
+      "kind": "Secret"
+    },
+    {
+      "kind": "ConfigMap"
+    }
+  ]
+}
+`,
+		},
+		{
+			name:   "json list stays a list",
+			data:   `{"apiVersion": "v1", "kind": "List", "items": [{"kind": "Secret"}]}`,
+			format: FormatJSON,
+			want: `{
+  "kind": "List",
+  "apiVersion": "v1",
+  "metadata": {},
+  "items": [
+    {
+      "kind": "Secret"
+    }
+  ]
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := readManifest([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := m.bytes(tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("bytes = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDocumentKind(t *testing.T) {
+	tests := []struct {
+		doc     string
+		want    string
+		wantErr bool
+	}{
+		{doc: "apiVersion: v1\nkind: Secret\n", want: "Secret"},
+		{doc: `{"kind": "SealedSecret"}`, want: "SealedSecret"},
+		{doc: "# only a comment\n", want: ""},
+		{doc: "kind: [\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := documentKind([]byte(tt.doc))
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("documentKind(%q) = %q, %v, want %q", tt.doc, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+var AllNamespaces bool
+var Selector string
+var OutputDir = "."
+var Layout = "{{.Namespace}}/{{.Name}}"
+
+func Pull(cmd *cobra.Command, args []string) error {
+	layout, err := template.New("layout").Option("missingkey=error").Parse(Layout)
+	if err != nil {
+		return fmt.Errorf("invalid layout: %v", err)
+	}
+
+	ns := ""
+	if !AllNamespaces {
+		ns, err = contextNamespace()
+		if err != nil {
+			return err
+		}
+	}
+
+	remote, err := listSealedSecrets(cmd.Context(), ns, Selector)
+	if err != nil {
+		return err
+	}
+
+	if len(remote) == 0 {
+		return newError(ExitNotFound, "no sealed secrets found")
+	}
+
+	keys := make([]string, 0, len(remote))
+	for k := range remote {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	// every file is checked before anything is written, so that a clash does not leave a partial pull
+	names := make(map[string]string, len(keys))
+	pulled := make(map[string][]byte, len(keys))
+	owners := make(map[string]string, len(keys))
+	for _, k := range keys {
+		sealedSecret := remote[k]
+		name, err := layoutOutputName(layout, &sealedSecret)
+		if err != nil {
+			return err
+		}
+
+		if owner, ok := owners[name]; ok {
+			return fmt.Errorf("layout gives %s for both %s and %s", name, owner, k)
+		}
+
+		data, err := pulledBytes(sealedSecret, fileFormat(name))
+		if err != nil {
+			return err
+		}
+
+		// pulling again only needs --force if a file was changed since
+		if existing, err := os.ReadFile(name); err == nil && !bytes.Equal(existing, data) && !Force {
+			return newError(ExitFileExists, "file %s already exists and differs from '%s', use --force to overwrite", name, k)
+		}
+
+		names[k] = name
+		pulled[k] = data
+		owners[name] = k
+	}
+
+	for _, k := range keys {
+		if existing, err := os.ReadFile(names[k]); err == nil && bytes.Equal(existing, pulled[k]) {
+			fmt.Printf("'%s' is unchanged in %s\n", k, names[k])
+			continue
+		}
+
+		err = os.MkdirAll(filepath.Dir(names[k]), 0755)
+		if err != nil {
+			return fmt.Errorf("unable to create directory: %v", err)
+		}
+
+		err = os.WriteFile(names[k], pulled[k], 0644)
+		if err != nil {
+			return fmt.Errorf("unable to write file: %v", err)
+		}
+
+		fmt.Printf("Pulled '%s' to %s\n", k, names[k])
+	}
+
+	fmt.Printf("Pulled %d sealed secrets into %s\n", len(keys), OutputDir)
+	return nil
+}
+
+// layoutOutputName returns the file an object is written to, the layout below --output-dir with the
+// extension of --format.
+func layoutOutputName(layout *template.Template, obj metav1.Object) (string, error) {
+	out := &bytes.Buffer{}
+	err := layout.Execute(out, obj)
+	if err != nil {
+		return "", fmt.Errorf("unable to apply layout to %s/%s: %v", obj.GetNamespace(), obj.GetName(), err)
+	}
+
+	name := filepath.Clean(filepath.FromSlash(out.String()))
+	if filepath.IsAbs(name) || name == "." || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("layout gives %s for %s/%s, which is outside --output-dir", out.String(), obj.GetNamespace(), obj.GetName())
+	}
+
+	return filepath.Join(OutputDir, name) + "." + string(fileFormat("")), nil
+}
+
+// pulledBytes returns a SealedSecret from the cluster as seal would have written it with --keep-template,
+// without anything the server or kubectl added. The template is always kept, as it holds the labels,
+// annotations and type of the unsealed Secret.
+func pulledBytes(sealedSecret v1alpha1.SealedSecret, format FileFormat) ([]byte, error) {
+	sealedSecret.ObjectMeta = metav1.ObjectMeta{
+		Name:        sealedSecret.Name,
+		Namespace:   sealedSecret.Namespace,
+		Labels:      sealedSecret.Labels,
+		Annotations: sealedSecret.Annotations,
+	}
+
+	v1alpha1.StripLastAppliedAnnotations(sealedSecret.Annotations)
+	delete(sealedSecret.Annotations, NamespaceKey)
+	if len(sealedSecret.Annotations) == 0 {
+		sealedSecret.Annotations = nil
+	}
+
+	sealedSecret.Spec.Template.Name = ""
+	sealedSecret.Spec.Template.Namespace = ""
+	sealedSecret.Status = nil
+	sealedSecret.APIVersion = v1alpha1.SchemeGroupVersion.String()
+	sealedSecret.Kind = "SealedSecret"
+
+	doc, err := marshalSealed(sealedSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return (&manifest{documents: [][]byte{doc}}).bytes(format)
+}
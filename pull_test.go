@@ -0,0 +1,130 @@
+package main
+
+import (
+	"github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"path/filepath"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestLayoutOutputName(t *testing.T) {
+	obj := &metav1.ObjectMeta{
+		Name:      "db",
+		Namespace: "app",
+		Labels:    map[string]string{"team": "payments"},
+	}
+
+	tests := []struct {
+		name    string
+		layout  string
+		format  FileFormat
+		want    string
+		wantErr bool
+	}{
+		{name: "default", layout: "{{.Namespace}}/{{.Name}}", want: "out/app/db.yaml"},
+		{name: "json", layout: "{{.Namespace}}/{{.Name}}", format: FormatJSON, want: "out/app/db.json"},
+		{name: "label", layout: `{{index .Labels "team"}}/{{.Name}}`, want: "out/payments/db.yaml"},
+		{name: "cleaned", layout: "./{{.Namespace}}//{{.Name}}", want: "out/app/db.yaml"},
+		{name: "inside after cleaning", layout: "{{.Namespace}}/../{{.Name}}", want: "out/db.yaml"},
+		{name: "parent", layout: "../{{.Name}}", wantErr: true},
+		{name: "absolute", layout: "/etc/{{.Name}}", wantErr: true},
+		{name: "empty", layout: "", wantErr: true},
+		{name: "missing label", layout: `{{index .Labels "owner"}}/{{.Name}}`, wantErr: true},
+		{name: "unknown field", layout: "{{.Cluster}}/{{.Name}}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldFormat, oldOutputDir := Format, OutputDir
+			t.Cleanup(func() { Format, OutputDir = oldFormat, oldOutputDir })
+			Format, OutputDir = tt.format, "out"
+
+			layout, err := template.New("layout").Option("missingkey=error").Parse(tt.layout)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := layoutOutputName(layout, obj)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && got != filepath.FromSlash(tt.want) {
+				t.Errorf("layoutOutputName = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPulledBytes(t *testing.T) {
+	sealedSecret := v1alpha1.SealedSecret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "db",
+			Namespace:         "app",
+			UID:               "0a1b2c",
+			ResourceVersion:   "42",
+			Generation:        3,
+			CreationTimestamp: metav1.NewTime(time.Unix(0, 0)),
+			Annotations: map[string]string{
+				"kubectl.kubernetes.io/last-applied-configuration": "{}",
+				NamespaceKey: "app",
+			},
+		},
+		Spec: v1alpha1.SealedSecretSpec{
+			EncryptedData: map[string]string{"password": "AgBy3i4O"},
+			Template: v1alpha1.SecretTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "db",
+					Namespace:   "app",
+					Labels:      map[string]string{"app": "db"},
+					Annotations: map[string]string{"owner": "payments"},
+				},
+			},
+		},
+		Status: &v1alpha1.SealedSecretStatus{ObservedGeneration: 3},
+	}
+
+	want := `apiVersion: bitnami.com/v1alpha1
+kind: SealedSecret
+metadata:
+  name: db
+  namespace: app
+spec:
+  encryptedData:
+    password: AgBy3i4O
+  template:
+    metadata:
+      annotations:
+        owner: payments
+      labels:
+        app: db
+`
+
+	tests := []struct {
+		name string
+		typ  corev1.SecretType
+		want string
+	}{
+		{name: "opaque", want: want},
+		{name: "typed", typ: corev1.SecretTypeTLS, want: want + "    type: kubernetes.io/tls\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := *sealedSecret.DeepCopy()
+			s.Spec.Template.Type = tt.typ
+
+			got, err := pulledBytes(s, FormatYAML)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("pulledBytes =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
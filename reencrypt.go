@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
+	"github.com/bitnami-labs/sealed-secrets/pkg/crypto"
+	"github.com/bitnami-labs/sealed-secrets/pkg/kubeseal"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes/scheme"
+	"os"
+	"sigs.k8s.io/yaml"
+)
+
+func Reencrypt(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return cmd.Help()
+	}
+
+	files, err := findFiles(args, Recursive, isSealedFile)
+	if err != nil {
+		return err
+	}
+
+	return runBatch(files, nil, !KeepGoing, func(input string, output string) error {
+		return reencrypt(cmd, input)
+	})
+}
+
+// reencrypt has the controller re-encrypt every SealedSecret in the file that was not sealed with its
+// current key. The file is left untouched if all of them already are.
+func reencrypt(cmd *cobra.Command, arg string) error {
+	data, err := readFile(arg)
+	if err != nil {
+		return err
+	}
+
+	m, err := readManifest(data)
+	if err != nil {
+		return fmt.Errorf("unable to read documents from %s: %v", arg, err)
+	}
+
+	key, err := getCurrentPrivateKey(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	client, err := getKubeClient()
+	if err != nil {
+		return newError(ExitClusterUnreachable, "unable to get kubernetes client: %v", err)
+	}
+
+	found := false
+	rotated := 0
+	for i, doc := range m.documents {
+		kind, err := documentKind(doc)
+		if err != nil {
+			return fmt.Errorf("unable to unmarshal document %d of %s: %v", i+1, arg, err)
+		}
+
+		if kind != "SealedSecret" {
+			continue
+		}
+
+		found = true
+		sealedSecret := v1alpha1.SealedSecret{}
+		err = yaml.Unmarshal(doc, &sealedSecret)
+		if err != nil {
+			return fmt.Errorf("unable to unmarshal secret: %v", err)
+		}
+
+		ns, err := resolveNamespace(sealedSecret.ObjectMeta)
+		if err != nil {
+			return err
+		}
+
+		nsFromFile := ns == sealedSecret.Namespace
+		sealedSecret.Namespace = ns
+
+		current, err := sealedWithKey(&sealedSecret, key)
+		if err != nil {
+			return fmt.Errorf("unable to check %s/%s: %v", ns, sealedSecret.Name, err)
+		}
+
+		if current {
+			continue
+		}
+
+		b, err := yaml.Marshal(sealedSecret)
+		if err != nil {
+			return fmt.Errorf("unable to marshal secret: %v", err)
+		}
+
+		w := &bytes.Buffer{}
+		err = kubeseal.ReEncryptSealedSecret(cmd.Context(), client, ControllerNamespace, ControllerName, "yaml", bytes.NewReader(b), w, scheme.Codecs)
+		if err != nil {
+			return newError(ExitClusterUnreachable, "unable to re-encrypt %s/%s: %v", ns, sealedSecret.Name, err)
+		}
+
+		reencrypted := v1alpha1.SealedSecret{}
+		err = yaml.Unmarshal(bytes.TrimPrefix(w.Bytes(), []byte("---\n")), &reencrypted)
+		if err != nil {
+			return fmt.Errorf("unable to unmarshal sealed secret: %v", err)
+		}
+
+		reencrypted.Status = nil
+		m.documents[i], err = formatSealed(reencrypted, ns, nsFromFile, KeepTemplate)
+		if err != nil {
+			return err
+		}
+
+		rotated++
+	}
+
+	if !found {
+		return newError(ExitNotFound, "no sealed secrets found in %s", arg)
+	}
+
+	if rotated == 0 {
+		fmt.Printf("%s is already sealed with the current key\n", arg)
+		return nil
+	}
+
+	data, err = m.bytes(fileFormat(arg))
+	if err != nil {
+		return fmt.Errorf("unable to marshal %s: %v", arg, err)
+	}
+
+	err = os.WriteFile(arg, data, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write file: %v", err)
+	}
+
+	fmt.Printf("Re-encrypted %d secret(s) in %s\n", rotated, arg)
+	return nil
+}
+
+// getCurrentPrivateKey returns the private key the controller currently seals with, found by matching the
+// fingerprint of its certificate against the active keys.
+func getCurrentPrivateKey(ctx context.Context) (*rsa.PrivateKey, error) {
+	publicKey, err := getPublicKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint, err := crypto.PublicKeyFingerprint(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := getPrivateKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := keys[fingerprint]
+	if !ok {
+		return nil, newError(ExitNotFound, "no private key matches the controller certificate %s", fingerprint)
+	}
+
+	return key, nil
+}
+
+// sealedWithKey reports whether every value of the SealedSecret was encrypted with key. The ciphertext does
+// not record which key was used, so the only way to tell is to try decrypting it.
+func sealedWithKey(sealedSecret *v1alpha1.SealedSecret, key *rsa.PrivateKey) (bool, error) {
+	fingerprint, err := crypto.PublicKeyFingerprint(&key.PublicKey)
+	if err != nil {
+		return false, err
+	}
+
+	keys := map[string]*rsa.PrivateKey{fingerprint: key}
+	label := v1alpha1.EncryptionLabel(sealedSecret.Namespace, sealedSecret.Name, v1alpha1.SecretScope(sealedSecret))
+
+	for k, v := range sealedSecret.Spec.EncryptedData {
+		ciphertext, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return false, fmt.Errorf("%s: %v", k, err)
+		}
+
+		if _, err := crypto.HybridDecrypt(rand.Reader, keys, ciphertext, label); err != nil {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
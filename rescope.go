@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
+	"github.com/bitnami-labs/sealed-secrets/pkg/crypto"
+	"github.com/spf13/cobra"
+	"os"
+	"sigs.k8s.io/yaml"
+)
+
+func Rescope(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return cmd.Help()
+	}
+
+	files, err := findFiles(args, Recursive, isSealedFile)
+	if err != nil {
+		return err
+	}
+
+	return runBatch(files, nil, !KeepGoing, func(input string, output string) error {
+		return rescope(cmd, input)
+	})
+}
+
+// rescope reseals every SealedSecret in the file that is not already sealed under --scope. The values are
+// decrypted and encrypted again in memory, as the scope is part of the encryption label.
+func rescope(cmd *cobra.Command, arg string) error {
+	m, secrets, err := unsealSecrets(cmd, arg)
+	if err != nil {
+		return err
+	}
+
+	rescoped := 0
+	for _, s := range secrets {
+		if v1alpha1.SecretScope(s.sealedSecret) == Scope {
+			continue
+		}
+
+		// the template and namespace are read from the file, as s.sealedSecret has the namespace resolved
+		original := v1alpha1.SealedSecret{}
+		err = yaml.Unmarshal(m.documents[s.index], &original)
+		if err != nil {
+			return fmt.Errorf("unable to unmarshal secret: %v", err)
+		}
+
+		ns := s.sealedSecret.Namespace
+		nsFromFile := ns == original.Namespace
+
+		sealedSecret := s.sealedSecret.DeepCopy()
+		key, err := getPublicKey(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("unable to get public key: %w", err)
+		}
+
+		sealedSecret.Spec.EncryptedData, err = encryptData(key, s, sealedSecret.Name, ns, Scope)
+		if err != nil {
+			return err
+		}
+
+		digests, err := unsealedDigests(digestLabel(ns, sealedSecret.Name, Scope), s)
+		if err != nil {
+			return err
+		}
+
+		err = setDigests(sealedSecret, digests)
+		if err != nil {
+			return err
+		}
+
+		sealedSecret.Annotations = scopeAnnotations(sealedSecret.Annotations)
+		sealedSecret.Spec.Template.Annotations = scopeAnnotations(sealedSecret.Spec.Template.Annotations)
+
+		m.documents[s.index], err = formatSealed(*sealedSecret, ns, nsFromFile, keepsTemplate(original))
+		if err != nil {
+			return err
+		}
+
+		rescoped++
+	}
+
+	if rescoped == 0 {
+		fmt.Printf("%s is already sealed with %s scope\n", arg, Scope.String())
+		return nil
+	}
+
+	data, err := m.bytes(fileFormat(arg))
+	if err != nil {
+		return fmt.Errorf("unable to marshal %s: %v", arg, err)
+	}
+
+	err = os.WriteFile(arg, data, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write file: %v", err)
+	}
+
+	fmt.Printf("Rescoped %d secret(s) in %s to %s scope\n", rescoped, arg, Scope.String())
+	return nil
+}
+
+// encryptData encrypts the decrypted values of every encrypted key of s for the given name, namespace and
+// scope. Keys that only come from the template are not encrypted, so they are left out.
+func encryptData(key *rsa.PublicKey, s unsealedSecret, name string, ns string, scope v1alpha1.SealingScope) (v1alpha1.SealedSecretEncryptedData, error) {
+	label := v1alpha1.EncryptionLabel(ns, name, scope)
+	encryptedData := make(map[string]string, len(s.sealedSecret.Spec.EncryptedData))
+	for k := range s.sealedSecret.Spec.EncryptedData {
+		ciphertext, err := crypto.HybridEncrypt(rand.Reader, key, s.secret.Data[k], label)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encrypt %s of %s: %v", k, s.sealedSecret.Name, err)
+		}
+
+		encryptedData[k] = base64.StdEncoding.EncodeToString(ciphertext)
+	}
+
+	return encryptedData, nil
+}
+
+// scopeAnnotations sets the scope annotations for --scope, which are none for strict.
+func scopeAnnotations(annotations map[string]string) map[string]string {
+	annotations = v1alpha1.UpdateScopeAnnotations(annotations, Scope)
+	if len(annotations) == 0 {
+		return nil
+	}
+
+	return annotations
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"math"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+var Staged bool
+var InstallHook bool
+var IgnoreFile = ".sealedsecretsignore"
+
+// MinEntropy is the Shannon entropy in bits per character above which a base64 value looks like a key or
+// password rather than text. Random base64 approaches 6, English text and paths stay around 4. A value of n
+// characters has at most log2(n) bits per character, so values shorter than 32 characters are held to 90% of
+// that instead, which still misses some random values of 20 to 40 characters.
+const MinEntropy = 4.5
+
+const preCommitHook = `#!/bin/sh
+# installed by sealedsecrets scan --install-hook
+exec sealedsecrets scan --staged
+`
+
+var secretKindLine = regexp.MustCompile(`^\s*(-\s+)?"?kind"?\s*:\s*["']?Secret["']?\s*,?\s*$`)
+var stringDataLine = regexp.MustCompile(`^\s*"?stringData"?\s*:`)
+var base64Value = regexp.MustCompile(`[A-Za-z0-9+/]{20,}={0,2}`)
+
+// leak is a possible plaintext secret, at line 0 if it is about the whole file.
+type leak struct {
+	file    string
+	line    int
+	message string
+}
+
+func Scan(cmd *cobra.Command, args []string) error {
+	if InstallHook {
+		return installPreCommitHook()
+	}
+
+	var leaks []leak
+	var err error
+	if Staged {
+		leaks, err = scanStaged()
+	} else {
+		leaks, err = scanTree(args)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, l := range leaks {
+		if l.line > 0 {
+			fmt.Printf("%s:%d: %s\n", l.file, l.line, l.message)
+		} else {
+			fmt.Printf("%s: %s\n", l.file, l.message)
+		}
+	}
+
+	if len(leaks) > 0 {
+		return newError(ExitLeaksFound, "found %d possible plaintext secrets, seal them or add them to %s", len(leaks), IgnoreFile)
+	}
+
+	fmt.Printf("No plaintext secrets found\n")
+	return nil
+}
+
+func scanTree(args []string) ([]leak, error) {
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	ignored, err := readIgnoreFile(IgnoreFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// a tree is always scanned as a whole, dot-directories such as .github included, only skipping the git database
+	files, err := walkFiles(args, true, func(name string) bool {
+		return isScannedFile(name) && !ignored.matches(filepath.ToSlash(filepath.Clean(name)))
+	}, func(name string) bool {
+		return name == ".git"
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var leaks []leak
+	for _, name := range files {
+		data, err := readFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		leaks = append(leaks, scanFile(name, data)...)
+	}
+
+	return leaks, nil
+}
+
+// scanStaged scans the content of the files in the git index, which is what is about to be committed, rather
+// than what is in the working tree.
+func scanStaged() ([]leak, error) {
+	top, err := git("", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+
+	root := strings.TrimSpace(string(top))
+	ignored, err := readIgnoreFile(filepath.Join(root, IgnoreFile))
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := git(root, "diff", "--cached", "--name-only", "--diff-filter=ACMR", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	var leaks []leak
+	for _, name := range strings.Split(string(names), "\x00") {
+		if name == "" || !isScannedFile(name) || ignored.matches(name) {
+			continue
+		}
+
+		data, err := git(root, "cat-file", "blob", ":"+name)
+		if err != nil {
+			return nil, err
+		}
+
+		leaks = append(leaks, scanFile(name, data)...)
+	}
+
+	return leaks, nil
+}
+
+func git(dir string, args ...string) ([]byte, error) {
+	c := exec.Command("git", args...)
+	c.Dir = dir
+	c.Stderr = os.Stderr
+
+	out, err := c.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %v", args[0], err)
+	}
+
+	return out, nil
+}
+
+func installPreCommitHook() error {
+	out, err := git("", "rev-parse", "--git-path", "hooks/pre-commit")
+	if err != nil {
+		return err
+	}
+
+	name := strings.TrimSpace(string(out))
+	err = checkOutputFile(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(name, []byte(preCommitHook), 0755)
+	if err != nil {
+		return fmt.Errorf("unable to write hook: %v", err)
+	}
+
+	fmt.Printf("Installed pre-commit hook %s\n", name)
+	return nil
+}
+
+// isScannedFile reports whether name may hold a manifest. .yml is included as other tools write it.
+func isScannedFile(name string) bool {
+	for _, ext := range Extensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+
+	return strings.HasSuffix(name, ".yml")
+}
+
+// scanFile returns the possible leaks in the file, at most one per line. SealedSecret documents and Lists of
+// them are skipped, as their encrypted values are meant to be committed.
+func scanFile(name string, data []byte) []leak {
+	var leaks []leak
+	if isUnsealedFile(name) {
+		leaks = append(leaks, leak{file: name, message: "unsealed file"})
+	}
+
+	lines := strings.Split(string(data), "\n")
+	start := 0
+	for start < len(lines) {
+		end := start + 1
+		for end < len(lines) && !strings.HasPrefix(lines[end], "---") {
+			end++
+		}
+
+		doc := lines[start:end]
+		if !isSealedDocument([]byte(strings.Join(doc, "\n"))) {
+			for i, line := range doc {
+				if message := scanLine(line); message != "" {
+					leaks = append(leaks, leak{file: name, line: start + i + 1, message: message})
+				}
+			}
+		}
+
+		start = end
+	}
+
+	return leaks
+}
+
+// isSealedDocument reports whether doc is a SealedSecret, or a List holding only SealedSecrets as written
+// for several documents in JSON.
+func isSealedDocument(doc []byte) bool {
+	kind, err := documentKind(doc)
+	if err != nil || kind != "List" {
+		return kind == "SealedSecret"
+	}
+
+	list := corev1.List{}
+	if err = yaml.Unmarshal(doc, &list); err != nil || len(list.Items) == 0 {
+		return false
+	}
+
+	for _, item := range list.Items {
+		if kind, err := documentKind(item.Raw); err != nil || kind != "SealedSecret" {
+			return false
+		}
+	}
+
+	return true
+}
+
+func scanLine(line string) string {
+	if secretKindLine.MatchString(line) {
+		return "plain Secret manifest"
+	}
+
+	if stringDataLine.MatchString(line) {
+		return "stringData block"
+	}
+
+	for _, value := range base64Value.FindAllString(line, -1) {
+		if entropy(value) >= minEntropy(len(value)) {
+			return "high-entropy value"
+		}
+	}
+
+	return ""
+}
+
+// minEntropy returns the entropy above which a base64 value of n characters is reported.
+func minEntropy(n int) float64 {
+	return math.Min(MinEntropy, 0.9*math.Log2(float64(n)))
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+
+	e := 0.0
+	for _, n := range counts {
+		p := float64(n) / float64(len(s))
+		e -= p * math.Log2(p)
+	}
+
+	return e
+}
+
+// ignoreList holds the patterns of an ignore file, which are matched against slash separated paths and
+// every parent directory of them, like .gitignore without negation.
+type ignoreList []string
+
+func readIgnoreFile(name string) (ignoreList, error) {
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read ignore file %s: %v", name, err)
+	}
+
+	var patterns ignoreList
+	for _, line := range strings.Split(string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, strings.TrimSuffix(strings.TrimPrefix(line, "/"), "/"))
+		}
+	}
+
+	return patterns, nil
+}
+
+func (l ignoreList) matches(name string) bool {
+	name = strings.TrimPrefix(name, "./")
+	for p := name; p != "." && p != "/" && p != ""; p = path.Dir(p) {
+		for _, pattern := range l {
+			if ok, _ := path.Match(pattern, p); ok {
+				return true
+			}
+
+			if ok, _ := path.Match(pattern, path.Base(p)); ok && !strings.Contains(pattern, "/") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
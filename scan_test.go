@@ -0,0 +1,145 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestScanLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{line: "kind: Secret", want: "plain Secret manifest"},
+		{line: `  "kind": "Secret",`, want: "plain Secret manifest"},
+		{line: "kind: 'Secret'", want: "plain Secret manifest"},
+		{line: "- kind: Secret", want: "plain Secret manifest"},
+		{line: "kind: SealedSecret", want: ""},
+		{line: "kind: SecretStore", want: ""},
+		{line: "stringData:", want: "stringData block"},
+		{line: `  "stringData": {`, want: "stringData block"},
+		{line: "  password: q8Zf3LmP0xWv7TbK2nRy", want: "high-entropy value"},
+		{line: "  tls.key: kX9pQ2vL7mZ0bR4tY8wN1cF6hJ3sA5dG0eU2iO7lK9M=", want: "high-entropy value"},
+		{line: "  greeting: aGVsbG8gd29ybGQgaGVsbG8gd29ybGQ=", want: ""},
+		{line: "  serviceAccountName: kubernetesioserviceaccount", want: ""},
+		{line: "  short: q8Zf3LmP0xWv7Tb", want: ""},
+		{line: "# just a comment", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := scanLine(tt.line); got != tt.want {
+			t.Errorf("scanLine(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestScanFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		data      string
+		wantLines []int
+	}{
+		{
+			name:      "plain secret",
+			file:      "secret.yaml",
+			data:      "apiVersion: v1\nkind: Secret\nstringData:\n  a: b\n",
+			wantLines: []int{2, 3},
+		},
+		{
+			name: "sealed secret",
+			file: "secret.yaml",
+			data: "apiVersion: bitnami.com/v1alpha1\nkind: SealedSecret\nspec:\n  encryptedData:\n    a: kX9pQ2vL7mZ0bR4tY8wN1cF6hJ3sA5dG0eU2iO7lK9M=\n",
+		},
+		{
+			name:      "only the unsealed document",
+			file:      "secrets.yaml",
+			data:      "kind: SealedSecret\nspec:\n  encryptedData:\n    a: kX9pQ2vL7mZ0bR4tY8wN1cF6hJ3sA5dG0eU2iO7lK9M=\n---\nkind: Secret\n",
+			wantLines: []int{6},
+		},
+		{
+			name: "yaml list of sealed secrets",
+			file: "secrets.yaml",
+			data: "apiVersion: v1\nkind: List\nitems:\n- kind: SealedSecret\n  spec:\n    encryptedData:\n      a: kX9pQ2vL7mZ0bR4tY8wN1cF6hJ3sA5dG0eU2iO7lK9M=\n",
+		},
+		{
+			name: "json list of sealed secrets",
+			file: "secrets.json",
+			data: "{\n  \"apiVersion\": \"v1\",\n  \"kind\": \"List\",\n  \"items\": [\n    {\n      \"kind\": \"SealedSecret\",\n      \"spec\": {\n        \"encryptedData\": {\n          \"a\": \"kX9pQ2vL7mZ0bR4tY8wN1cF6hJ3sA5dG0eU2iO7lK9M=\"\n        }\n      }\n    }\n  ]\n}\n",
+		},
+		{
+			name:      "list with a plain secret",
+			file:      "secrets.yaml",
+			data:      "apiVersion: v1\nkind: List\nitems:\n- kind: SealedSecret\n- kind: Secret\n  data:\n    a: kX9pQ2vL7mZ0bR4tY8wN1cF6hJ3sA5dG0eU2iO7lK9M=\n",
+			wantLines: []int{5, 7},
+		},
+		{
+			name:      "unsealed file",
+			file:      "secret.unsealed.yaml",
+			data:      "kind: SealedSecret\n",
+			wantLines: []int{0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []int
+			for _, l := range scanFile(tt.file, []byte(tt.data)) {
+				lines = append(lines, l.line)
+			}
+
+			if !reflect.DeepEqual(lines, tt.wantLines) {
+				t.Errorf("leaks at lines %v, want %v", lines, tt.wantLines)
+			}
+		})
+	}
+}
+
+func TestScanTree(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		".github/workflows/secret.yaml": "kind: Secret\n",
+		".git/objects/secret.yaml":      "kind: Secret\n",
+		"app/secret.unsealed.yml":       "kind: SealedSecret\n",
+		"app/sealed.yaml":               "kind: SealedSecret\n",
+	}
+
+	for name, data := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldIgnoreFile := IgnoreFile
+	t.Cleanup(func() { IgnoreFile = oldIgnoreFile })
+	IgnoreFile = filepath.Join(dir, ".sealedsecretsignore")
+
+	leaks, err := scanTree([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, l := range leaks {
+		rel, err := filepath.Rel(dir, l.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got = append(got, filepath.ToSlash(rel))
+	}
+
+	sort.Strings(got)
+	want := []string{".github/workflows/secret.yaml", "app/secret.unsealed.yml"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("leaks in %v, want %v", got, want)
+	}
+}
@@ -2,15 +2,19 @@ package main
 
 import (
 	"bytes"
+	"crypto/rsa"
 	"errors"
 	"fmt"
 	"github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
 	"github.com/bitnami-labs/sealed-secrets/pkg/kubeseal"
 	"github.com/spf13/cobra"
 	corev1 "k8s.io/api/core/v1"
-	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	"os"
+	"path/filepath"
+	"reflect"
 	"sigs.k8s.io/yaml"
 	"strings"
 )
@@ -18,209 +22,330 @@ import (
 var Reseal bool
 var KeepTemplate bool
 var Scope v1alpha1.SealingScope
+var CertURL string
 
 func Seal(cmd *cobra.Command, args []string) error {
 	if len(args) == 0 {
 		return cmd.Help()
 	}
 
-	if len(args) > 1 {
+	if len(args) > 1 || Recursive || isDir(args[0]) {
 		if OutputFile != "" {
 			return fmt.Errorf("cannot specify output file with multiple input files")
 		}
 
-		for _, arg := range args {
-			outputName := strings.TrimSuffix(arg, ".unsealed.yaml") + ".yaml"
-			err := seal(cmd, arg, outputName)
-			if err != nil {
-				if errors.Is(err, ErrStop) {
-					return nil
-				}
-
-				return err
-			}
-		}
-
-		return nil
-	} else {
-		outputName := OutputFile
-		if outputName == "" {
-			outputName = strings.TrimSuffix(args[0], ".unsealed.yaml") + ".yaml"
-		}
-
-		err := seal(cmd, args[0], outputName)
+		files, err := findFiles(args, Recursive, isUnsealedFile)
 		if err != nil {
-			if errors.Is(err, ErrStop) {
-				return nil
-			}
-
 			return err
 		}
+
+		return runBatch(files, sealOutputName, !KeepGoing, func(input string, output string) error {
+			return seal(cmd, input, output)
+		})
 	}
 
-	return nil
+	outputName := OutputFile
+	if outputName == "" {
+		outputName = sealOutputName(args[0])
+	}
+
+	return seal(cmd, args[0], outputName)
 }
 
 func seal(cmd *cobra.Command, arg string, outputName string) error {
+	if filepath.Clean(outputName) == filepath.Clean(arg) {
+		return fmt.Errorf("output file %s is the input file", outputName)
+	}
+
 	// if outputName exists
 	_, err := os.Stat(outputName)
 	exists := err == nil
 	if exists && !Force {
-		ErrorLogger.Printf("output file %s already exists, use --force to overwrite", outputName)
-		return ErrStop
+		return newError(ExitFileExists, "output file %s already exists, use --force to overwrite", outputName)
 	}
 
-	sourceData, err := os.ReadFile(arg)
+	sourceData, err := readFile(arg)
 	if err != nil {
-		ErrorLogger.Printf("unable to read file %s: %v", arg, err)
-		return ErrStop
+		return err
 	}
 
-	sourceSecret := corev1.Secret{}
-	err = yaml.Unmarshal(sourceData, &sourceSecret)
+	source, err := readManifest(sourceData)
 	if err != nil {
-		ErrorLogger.Printf("unable to unmarshal source secret %s: %v", arg, err)
-		return ErrStop
+		return fmt.Errorf("unable to read documents from %s: %v", arg, err)
 	}
 
+	return sealSource(cmd, arg, outputName, source)
+}
+
+// sealSource seals source to outputName, only re-encrypting the changed keys if outputName exists, unless
+// --reseal is set. Changed keys are found by digest if --digest-key is set and every key in outputName has
+// one, and otherwise by decrypting it. arg names where source came from in messages.
+func sealSource(cmd *cobra.Command, arg string, outputName string, source *manifest) error {
 	reseal := Reseal
-	if _, err = os.Stat(outputName); errors.Is(err, os.ErrNotExist) {
+	if _, err := os.Stat(outputName); errors.Is(err, os.ErrNotExist) {
 		reseal = true
 	}
 
-	var originalSealedSecret *v1alpha1.SealedSecret
-	skipped := make([]string, 0, len(sourceSecret.Data))
+	var original *manifest
+	var originals []unsealedSecret
 	if !reseal {
-		originalSecret := corev1.Secret{}
+		var err error
+		original, originals, err = digestedSecrets(outputName)
+		if err == nil && original == nil {
+			original, originals, err = unsealSecrets(cmd, outputName)
+		} else if err == nil {
+			fmt.Printf("Comparing with %s by digest\n", outputName)
+		}
 
-		var originalSecretData string
-		originalSecretData, originalSealedSecret, err = unsealSecret(cmd, outputName)
 		if err != nil {
 			return err
 		}
+	}
 
-		err = yaml.Unmarshal([]byte(originalSecretData), &originalSecret)
+	return sealManifest(cmd, arg, outputName, source, original, originals)
+}
+
+// sealManifest seals every Secret in source and writes the result to outputName, leaving any other
+// documents as they are. Secrets that match one of originals only have their changed keys re-encrypted,
+// and are written back exactly as they were in original if nothing changed.
+func sealManifest(cmd *cobra.Command, arg string, outputName string, source *manifest, original *manifest, originals []unsealedSecret) error {
+	var key *rsa.PublicKey
+	found := false
+	for i, doc := range source.documents {
+		kind, err := documentKind(doc)
+		if err != nil {
+			return fmt.Errorf("unable to unmarshal document %d of %s: %v", i+1, arg, err)
+		}
+
+		if kind != "Secret" {
+			continue
+		}
+
+		found = true
+		sourceSecret := corev1.Secret{}
+		err = yaml.Unmarshal(doc, &sourceSecret)
 		if err != nil {
-			ErrorLogger.Printf("unable to unmarshal original secret %s: %v", arg, err)
-			return ErrStop
+			return fmt.Errorf("unable to unmarshal source secret %s: %v", arg, err)
 		}
 
-		for k, original := range originalSecret.Data {
-			source := sourceSecret.Data[k]
-			if source == nil {
-				source = []byte(sourceSecret.StringData[k])
+		ns, err := resolveNamespace(sourceSecret.ObjectMeta)
+		if err != nil {
+			return err
+		}
+
+		var originalSecret *unsealedSecret
+		for j := range originals {
+			if originals[j].sealedSecret.Name == sourceSecret.Name && originals[j].sealedSecret.Namespace == ns {
+				originalSecret = &originals[j]
+				break
 			}
+		}
 
-			if source == nil {
-				if sourceSecret.Data == nil {
-					sourceSecret.Data = make(map[string][]byte)
+		scope := Scope
+		var skipped []string
+		if originalSecret != nil {
+			scope, err = resealScope(cmd, &sourceSecret, originalSecret)
+			if err != nil {
+				return err
+			}
+
+			if originalSecret.secret != nil {
+				skipped = skipUnchanged(&sourceSecret, originalSecret.secret)
+			} else {
+				skipped, err = skipDigested(digestLabel(ns, sourceSecret.Name, scope), &sourceSecret, originalSecret)
+				if err != nil {
+					return err
 				}
+			}
 
-				sourceSecret.Data[k] = original
-			} else if string(original) == string(source) {
-				delete(sourceSecret.StringData, k)
-				delete(sourceSecret.Data, k)
-				skipped = append(skipped, k)
+			if len(skipped) > 0 {
+				fmt.Printf("Skipped %d unchanged keys in '%s': %s\n", len(skipped), sourceSecret.Name, strings.Join(skipped, ", "))
 			}
-		}
 
-		if len(skipped) > 0 {
-			fmt.Printf("Skipped %d unchanged keys: %s\n", len(skipped), strings.Join(skipped, ", "))
+			if len(sourceSecret.Data) == 0 && len(sourceSecret.StringData) == 0 {
+				source.documents[i] = original.documents[originalSecret.index]
+				continue
+			}
 		}
 
-		if len(sourceSecret.Data) == 0 && len(sourceSecret.StringData) == 0 {
-			fmt.Printf("No changes to seal\n")
-			return nil
+		if key == nil {
+			key, err = getPublicKey(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("unable to get public key: %w", err)
+			}
 		}
 
-		sourceData, err = yaml.Marshal(sourceSecret)
+		source.documents[i], err = sealSecret(key, sourceSecret, ns, scope, skipped, originalSecret)
 		if err != nil {
-			ErrorLogger.Printf("unable to marshal source secret: %v", err)
-			return ErrStop
+			return err
 		}
 	}
 
-	client, err := getKubeClient()
+	if !found {
+		return newError(ExitNotFound, "no secrets found in %s", arg)
+	}
+
+	data, err := source.bytes(fileFormat(outputName))
 	if err != nil {
-		return fmt.Errorf("unable to get kubernetes client: %v", err)
+		return fmt.Errorf("unable to marshal sealed secrets: %v", err)
 	}
 
-	key, err := getPublicKey(cmd.Context())
+	if existing, err := os.ReadFile(outputName); err == nil && bytes.Equal(existing, data) {
+		fmt.Printf("No changes to seal\n")
+		return nil
+	}
+
+	err = os.WriteFile(outputName, data, 0644)
 	if err != nil {
-		ErrorLogger.Printf("unable to get private key: %v", err)
-		return ErrStop
+		return fmt.Errorf("unable to write to file %s: %v", outputName, err)
+	}
+
+	fmt.Printf("Sealed secret from %s to %s\n", arg, outputName)
+	return nil
+}
+
+// resealScope returns the scope to partially reseal sourceSecret with, which is the scope originalSecret was
+// sealed with. The controller cannot decrypt a secret whose keys are sealed under different scopes, so asking
+// for another scope, with --scope or the scope annotations of the source, is an error unless --reseal is set.
+func resealScope(cmd *cobra.Command, sourceSecret *corev1.Secret, originalSecret *unsealedSecret) (v1alpha1.SealingScope, error) {
+	scope := v1alpha1.SecretScope(originalSecret.sealedSecret)
+
+	requested := scope
+	if cmd.Flags().Changed("scope") {
+		requested = Scope
+	} else if v1alpha1.SecretScope(sourceSecret) != v1alpha1.StrictScope {
+		// unseal does not keep the scope annotations, so only a source that has them asks for a scope
+		requested = v1alpha1.SecretScope(sourceSecret)
 	}
 
-	nsFromFile := false
-	ns := Namespace
-	nsAnno, ok := sourceSecret.ObjectMeta.Annotations[NamespaceKey]
-	if ns == "" && sourceSecret.Namespace == "" && (!ok || nsAnno == "") {
-		ErrorLogger.Printf("unable to determine namespace\n")
-		return ErrStop
-	} else if ns == "" && sourceSecret.Namespace != "" {
-		fmt.Printf("Using namespace from secret\n")
-		ns = sourceSecret.Namespace
-	} else if ns == "" && ok && nsAnno != "" {
-		fmt.Printf("Using namespace from annotation\n")
-		ns = nsAnno
+	if requested != scope {
+		return scope, fmt.Errorf("'%s' is sealed with %s scope and cannot be partially resealed with %s scope, use --reseal", sourceSecret.Name, scope.String(), requested.String())
 	}
 
-	if ns == sourceSecret.Namespace {
-		nsFromFile = true
+	return scope, nil
+}
+
+// skipUnchanged removes the keys of sourceSecret that have the same value in originalSecret, returning their names.
+func skipUnchanged(sourceSecret *corev1.Secret, originalSecret *corev1.Secret) []string {
+	skipped := make([]string, 0, len(sourceSecret.Data))
+	for k, original := range originalSecret.Data {
+		source := sourceSecret.Data[k]
+		if source == nil {
+			source = []byte(sourceSecret.StringData[k])
+		}
+
+		if source == nil {
+			if sourceSecret.Data == nil {
+				sourceSecret.Data = make(map[string][]byte)
+			}
+
+			sourceSecret.Data[k] = original
+		} else if string(original) == string(source) {
+			delete(sourceSecret.StringData, k)
+			delete(sourceSecret.Data, k)
+			skipped = append(skipped, k)
+		}
+	}
+
+	return skipped
+}
+
+// sealSecret seals sourceSecret into namespace ns with scope. The skipped keys are copied over from originalSecret.
+func sealSecret(key *rsa.PublicKey, sourceSecret corev1.Secret, ns string, scope v1alpha1.SealingScope, skipped []string, originalSecret *unsealedSecret) ([]byte, error) {
+	sourceData, err := yaml.Marshal(sourceSecret)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal source secret: %v", err)
 	}
 
+	// the client is only used by kubeseal to look up a default namespace, which
+	// is always resolved by now, so sealing with a certificate works offline
+	client, _ := getKubeClient()
+
+	nsFromFile := ns == sourceSecret.Namespace
+
 	r := bytes.NewReader(sourceData)
 	w := &bytes.Buffer{}
 
-	err = kubeseal.Seal(client, "yaml", r, w, scheme.Codecs, key, Scope, true, sourceSecret.ObjectMeta.Name, ns)
+	err = kubeseal.Seal(client, "yaml", r, w, scheme.Codecs, key, scope, true, sourceSecret.ObjectMeta.Name, ns)
 	if err != nil {
-		ErrorLogger.Printf("unable to seal secret: %v", err)
-		return ErrStop
+		return nil, fmt.Errorf("unable to seal secret: %v", err)
 	}
 
 	sealedSecret := v1alpha1.SealedSecret{}
 	err = yaml.Unmarshal(w.Bytes(), &sealedSecret)
 	if err != nil {
-		ErrorLogger.Printf("unable to unmarshal sealed secret: %v", err)
-		return ErrStop
+		return nil, fmt.Errorf("unable to unmarshal sealed secret: %v", err)
 	}
 
-	if !reseal {
-		for _, k := range skipped {
-			sealedSecret.Spec.EncryptedData[k] = originalSealedSecret.Spec.EncryptedData[k]
-		}
+	for _, k := range skipped {
+		sealedSecret.Spec.EncryptedData[k] = originalSecret.sealedSecret.Spec.EncryptedData[k]
+	}
+
+	// kubeseal keeps the scope annotated on the source unless given another than strict, so the label takes
+	// the scope that was sealed with
+	digests, err := secretDigests(digestLabel(ns, sourceSecret.Name, v1alpha1.SecretScope(&sealedSecret)), &sourceSecret, skipped, originalSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	err = setDigests(&sealedSecret, digests)
+	if err != nil {
+		return nil, err
 	}
 
-	if !KeepTemplate {
+	return formatSealed(sealedSecret, ns, nsFromFile, KeepTemplate)
+}
+
+// formatSealed marshals a SealedSecret the way sealed files are written: without a creationTimestamp, without
+// the template unless keepTemplate is set, and with the namespace annotation if ns is not from the file.
+// The secret type is always kept, as the controller would otherwise create an Opaque secret.
+func formatSealed(sealedSecret v1alpha1.SealedSecret, ns string, nsFromFile bool, keepTemplate bool) ([]byte, error) {
+	if !keepTemplate {
+		secretType := sealedSecret.Spec.Template.Type
 		sealedSecret.Spec.Template = v1alpha1.SecretTemplateSpec{}
+		if secretType != corev1.SecretTypeOpaque {
+			sealedSecret.Spec.Template.Type = secretType
+		}
 	} else if !nsFromFile {
+		if sealedSecret.ObjectMeta.Annotations == nil {
+			sealedSecret.ObjectMeta.Annotations = make(map[string]string)
+		}
+
 		sealedSecret.ObjectMeta.Annotations[NamespaceKey] = ns
 	} else if nsFromFile {
 		delete(sealedSecret.ObjectMeta.Annotations, NamespaceKey)
 		sealedSecret.Namespace = ns
 	}
 
-	sealedSecret.ObjectMeta.CreationTimestamp = v1.Time{}
+	return marshalSealed(sealedSecret)
+}
+
+// keepsTemplate reports whether a SealedSecret sealed again in place keeps its template: with --keep-template,
+// and otherwise if the file had one, so that rewriting a file never drops what it held.
+func keepsTemplate(original v1alpha1.SealedSecret) bool {
+	return KeepTemplate || !reflect.DeepEqual(original.Spec.Template, v1alpha1.SecretTemplateSpec{})
+}
 
-	data, err := yaml.Marshal(sealedSecret)
+// marshalSealed marshals a SealedSecret without the fields that are empty but not omitted by their types: the
+// null creationTimestamps and an empty template or template metadata.
+func marshalSealed(sealedSecret v1alpha1.SealedSecret) ([]byte, error) {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&sealedSecret)
 	if err != nil {
-		ErrorLogger.Printf("unable to marshal sealed secret: %v", err)
-		return ErrStop
+		return nil, fmt.Errorf("unable to convert sealed secret: %v", err)
 	}
 
-	data = bytes.Replace(data, []byte("  creationTimestamp: null\n"), []byte(""), -1)
-	data = bytes.TrimPrefix(data, []byte("---\n"))
-
-	if !KeepTemplate {
-		data = bytes.Replace(data, []byte("  template:\n    metadata:\n    "), []byte(""), -1)
+	unstructured.RemoveNestedField(obj, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(obj, "spec", "template", "metadata", "creationTimestamp")
+	for _, fields := range [][]string{{"spec", "template", "metadata"}, {"spec", "template"}} {
+		if m, ok, _ := unstructured.NestedMap(obj, fields...); ok && len(m) == 0 {
+			unstructured.RemoveNestedField(obj, fields...)
+		}
 	}
 
-	err = os.WriteFile(outputName, data, 0644)
+	data, err := yaml.Marshal(obj)
 	if err != nil {
-		ErrorLogger.Printf("unable to write to file %s: %v", outputName, err)
-		return ErrStop
+		return nil, fmt.Errorf("unable to marshal sealed secret: %v", err)
 	}
 
-	fmt.Printf("Sealed secret from %s to %s\n", arg, outputName)
-	return nil
+	return data, nil
 }
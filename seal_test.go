@@ -0,0 +1,104 @@
+package main
+
+import (
+	"github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSkipUnchanged(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      corev1.Secret
+		original    map[string][]byte
+		wantSkipped []string
+		wantData    map[string][]byte
+		wantString  map[string]string
+	}{
+		{
+			name:        "unchanged data is skipped",
+			source:      corev1.Secret{Data: map[string][]byte{"a": []byte("1"), "b": []byte("2")}},
+			original:    map[string][]byte{"a": []byte("1"), "b": []byte("old")},
+			wantSkipped: []string{"a"},
+			wantData:    map[string][]byte{"b": []byte("2")},
+		},
+		{
+			name:        "stringData is compared with the decrypted value",
+			source:      corev1.Secret{StringData: map[string]string{"a": "1", "b": "2"}},
+			original:    map[string][]byte{"a": []byte("1"), "b": []byte("old")},
+			wantSkipped: []string{"a"},
+			wantString:  map[string]string{"b": "2"},
+		},
+		{
+			name:        "new keys are sealed",
+			source:      corev1.Secret{Data: map[string][]byte{"a": []byte("1"), "c": []byte("3")}},
+			original:    map[string][]byte{"a": []byte("1")},
+			wantSkipped: []string{"a"},
+			wantData:    map[string][]byte{"c": []byte("3")},
+		},
+		{
+			name:        "keys removed from the source are dropped",
+			source:      corev1.Secret{Data: map[string][]byte{"a": []byte("1")}},
+			original:    map[string][]byte{"a": []byte("1"), "b": []byte("2")},
+			wantSkipped: []string{"a"},
+			wantData:    map[string][]byte{},
+		},
+		{
+			name:        "everything changed",
+			source:      corev1.Secret{Data: map[string][]byte{"a": []byte("new")}},
+			original:    map[string][]byte{"a": []byte("old")},
+			wantSkipped: []string{},
+			wantData:    map[string][]byte{"a": []byte("new")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := tt.source.DeepCopy()
+			skipped := skipUnchanged(source, &corev1.Secret{Data: tt.original})
+			sort.Strings(skipped)
+
+			if !reflect.DeepEqual(skipped, tt.wantSkipped) {
+				t.Errorf("skipped = %v, want %v", skipped, tt.wantSkipped)
+			}
+
+			if len(source.Data) != len(tt.wantData) || (len(tt.wantData) > 0 && !reflect.DeepEqual(source.Data, tt.wantData)) {
+				t.Errorf("data = %v, want %v", source.Data, tt.wantData)
+			}
+
+			if len(source.StringData) != len(tt.wantString) || (len(tt.wantString) > 0 && !reflect.DeepEqual(source.StringData, tt.wantString)) {
+				t.Errorf("stringData = %v, want %v", source.StringData, tt.wantString)
+			}
+		})
+	}
+}
+
+func TestKeepsTemplate(t *testing.T) {
+	tests := []struct {
+		name         string
+		keepTemplate bool
+		template     v1alpha1.SecretTemplateSpec
+		want         bool
+	}{
+		{name: "no template", want: false},
+		{name: "--keep-template", keepTemplate: true, want: true},
+		{name: "template in the file", template: v1alpha1.SecretTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "db"}}}, want: true},
+		{name: "type only", template: v1alpha1.SecretTemplateSpec{Type: corev1.SecretTypeTLS}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := KeepTemplate
+			t.Cleanup(func() { KeepTemplate = old })
+			KeepTemplate = tt.keepTemplate
+
+			original := v1alpha1.SealedSecret{Spec: v1alpha1.SealedSecretSpec{Template: tt.template}}
+			if got := keepsTemplate(original); got != tt.want {
+				t.Errorf("keepsTemplate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
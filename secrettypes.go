@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"strings"
+	"time"
+)
+
+var TLSCertFile string
+var TLSKeyFile string
+
+var Username string
+var Password string
+var Email string
+var RegistryServer = "https://index.docker.io/v1/"
+
+func CreateTLS(cmd *cobra.Command, args []string) error {
+	secret, err := newSecret(args[0], corev1.SecretTypeTLS)
+	if err != nil {
+		return err
+	}
+
+	certData, err := readFile(TLSCertFile)
+	if err != nil {
+		return err
+	}
+
+	keyData, err := readFile(TLSKeyFile)
+	if err != nil {
+		return err
+	}
+
+	err = checkKeyPair(certData, keyData)
+	if err != nil {
+		return err
+	}
+
+	secret.Data[corev1.TLSCertKey] = certData
+	secret.Data[corev1.TLSPrivateKeyKey] = keyData
+	return sealNewSecret(cmd, secret)
+}
+
+// checkKeyPair checks that the key belongs to the first certificate in the chain, and that the certificate
+// is currently valid.
+func checkKeyPair(certData []byte, keyData []byte) error {
+	pair, err := tls.X509KeyPair(certData, keyData)
+	if err != nil {
+		return fmt.Errorf("invalid certificate and key %s, %s: %v", TLSCertFile, TLSKeyFile, err)
+	}
+
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("unable to parse certificate %s: %v", TLSCertFile, err)
+	}
+
+	if time.Now().After(cert.NotAfter) {
+		return fmt.Errorf("certificate %s expired on %s", TLSCertFile, cert.NotAfter.Format(time.RFC3339))
+	} else if time.Now().Before(cert.NotBefore) {
+		return fmt.Errorf("certificate %s is not valid until %s", TLSCertFile, cert.NotBefore.Format(time.RFC3339))
+	}
+
+	warnExpiry(cert, TLSCertFile)
+	return nil
+}
+
+// dockerConfigJSON is the content of a .dockerconfigjson key, as read by the kubelet.
+type dockerConfigJSON struct {
+	Auths map[string]dockerConfigEntry `json:"auths"`
+}
+
+type dockerConfigEntry struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Auth     string `json:"auth,omitempty"`
+}
+
+func CreateDockerRegistry(cmd *cobra.Command, args []string) error {
+	secret, err := newSecret(args[0], corev1.SecretTypeDockerConfigJson)
+	if err != nil {
+		return err
+	}
+
+	if RegistryServer == "" {
+		return fmt.Errorf("--server must not be empty")
+	}
+
+	// the auth field is user:password, so the username cannot contain a colon
+	if strings.Contains(Username, ":") {
+		return fmt.Errorf("username must not contain ':'")
+	}
+
+	data, err := json.Marshal(dockerConfigJSON{
+		Auths: map[string]dockerConfigEntry{
+			RegistryServer: {
+				Username: Username,
+				Password: Password,
+				Email:    Email,
+				Auth:     base64.StdEncoding.EncodeToString([]byte(Username + ":" + Password)),
+			},
+		},
+	})
+
+	if err != nil {
+		return fmt.Errorf("unable to marshal docker config: %v", err)
+	}
+
+	secret.Data[corev1.DockerConfigJsonKey] = data
+	return sealNewSecret(cmd, secret)
+}
+
+func CreateBasicAuth(cmd *cobra.Command, args []string) error {
+	secret, err := newSecret(args[0], corev1.SecretTypeBasicAuth)
+	if err != nil {
+		return err
+	}
+
+	// the API server requires at least one of the two
+	if Username == "" && Password == "" {
+		return fmt.Errorf("at least one of --username and --password is needed")
+	}
+
+	if Username != "" {
+		secret.Data[corev1.BasicAuthUsernameKey] = []byte(Username)
+	}
+
+	if Password != "" {
+		secret.Data[corev1.BasicAuthPasswordKey] = []byte(Password)
+	}
+
+	return sealNewSecret(cmd, secret)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// Shamir's secret sharing over GF(2^8), applied to every byte of the secret independently. A share is the
+// value of a random polynomial of degree threshold-1 at its x coordinate, with the secret as the constant term.
+
+var gfExp [510]byte
+var gfLog [256]byte
+
+func init() {
+	// 3 generates the multiplicative group of GF(2^8) with the AES polynomial x^8 + x^4 + x^3 + x + 1
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		gfExp[i] = x
+		gfExp[i+255] = x
+		gfLog[x] = byte(i)
+		x ^= gfDouble(x)
+	}
+}
+
+func gfDouble(x byte) byte {
+	if x&0x80 != 0 {
+		return x<<1 ^ 0x1b
+	}
+
+	return x << 1
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
+
+// shamirSplit splits secret into n shares, any threshold of which recover it. Share i has x coordinate i+1.
+func shamirSplit(secret []byte, n, threshold int) ([][]byte, error) {
+	if threshold < 2 || threshold > n || n > 255 {
+		return nil, fmt.Errorf("threshold must be between 2 and the number of shares, which must be at most 255")
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret))
+	}
+
+	coefficients := make([]byte, threshold-1)
+	for j, b := range secret {
+		if _, err := rand.Read(coefficients); err != nil {
+			return nil, err
+		}
+
+		for i := range shares {
+			// Horner's method, the secret is the constant term
+			x := byte(i + 1)
+			y := byte(0)
+			for c := len(coefficients) - 1; c >= 0; c-- {
+				y = gfMul(y, x) ^ coefficients[c]
+			}
+
+			shares[i][j] = gfMul(y, x) ^ b
+		}
+	}
+
+	return shares, nil
+}
+
+// shamirCombine recovers the secret from shares with the x coordinates in xs, by interpolating at 0.
+func shamirCombine(xs []byte, shares [][]byte) ([]byte, error) {
+	if len(xs) != len(shares) || len(shares) == 0 {
+		return nil, fmt.Errorf("no shares")
+	}
+
+	for i := range xs {
+		if xs[i] == 0 {
+			return nil, fmt.Errorf("invalid share index 0")
+		}
+
+		if len(shares[i]) != len(shares[0]) {
+			return nil, fmt.Errorf("shares have different lengths")
+		}
+
+		for j := 0; j < i; j++ {
+			if xs[i] == xs[j] {
+				return nil, fmt.Errorf("share %d is given more than once", xs[i])
+			}
+		}
+	}
+
+	// the Lagrange basis polynomials at 0 only depend on the x coordinates
+	basis := make([]byte, len(xs))
+	for i := range xs {
+		basis[i] = 1
+		for j := range xs {
+			if i != j {
+				// in GF(2^8) subtraction is xor, so x_j / (x_j - x_i) is x_j / (x_j ^ x_i)
+				basis[i] = gfMul(basis[i], gfDiv(xs[j], xs[j]^xs[i]))
+			}
+		}
+	}
+
+	secret := make([]byte, len(shares[0]))
+	for k := range secret {
+		for i := range shares {
+			secret[k] ^= gfMul(shares[i][k], basis[i])
+		}
+	}
+
+	return secret, nil
+}
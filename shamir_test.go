@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// subsets returns every subset of k of the indexes 0 to n-1.
+func subsets(n, k int) [][]int {
+	if k == 0 {
+		return [][]int{{}}
+	}
+
+	var result [][]int
+	for i := k - 1; i < n; i++ {
+		for _, s := range subsets(i, k-1) {
+			result = append(result, append(s, i))
+		}
+	}
+
+	return result
+}
+
+func TestShamirSubsets(t *testing.T) {
+	secret := []byte("apiVersion: v1\nkind: List\nitems: []\n\x00\xff")
+
+	tests := []struct {
+		n, threshold int
+	}{
+		{n: 2, threshold: 2},
+		{n: 3, threshold: 2},
+		{n: 5, threshold: 3},
+		{n: 6, threshold: 6},
+	}
+
+	for _, tt := range tests {
+		shares, err := shamirSplit(secret, tt.n, tt.threshold)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for k := 1; k <= tt.n; k++ {
+			for _, subset := range subsets(tt.n, k) {
+				xs := make([]byte, len(subset))
+				parts := make([][]byte, len(subset))
+				for i, s := range subset {
+					xs[i], parts[i] = byte(s+1), shares[s]
+				}
+
+				combined, err := shamirCombine(xs, parts)
+				if err != nil {
+					t.Fatalf("%d of %d, shares %v: %v", tt.threshold, tt.n, xs, err)
+				}
+
+				if recovered := bytes.Equal(combined, secret); recovered != (k >= tt.threshold) {
+					t.Errorf("%d of %d, shares %v: recovered = %v", tt.threshold, tt.n, xs, recovered)
+				}
+			}
+		}
+	}
+}
+
+func TestShamirSplitInvalid(t *testing.T) {
+	tests := []struct {
+		name         string
+		n, threshold int
+	}{
+		{name: "threshold of 1", n: 3, threshold: 1},
+		{name: "threshold above shares", n: 3, threshold: 4},
+		{name: "too many shares", n: 256, threshold: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := shamirSplit([]byte("secret"), tt.n, tt.threshold); err == nil {
+				t.Errorf("split %d of %d", tt.threshold, tt.n)
+			}
+		})
+	}
+}
+
+func TestShamirCombineInvalid(t *testing.T) {
+	shares, err := shamirSplit([]byte("secret"), 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		xs     []byte
+		shares [][]byte
+	}{
+		{name: "no shares"},
+		{name: "duplicate share", xs: []byte{1, 1}, shares: [][]byte{shares[0], shares[0]}},
+		{name: "index 0", xs: []byte{0, 1}, shares: [][]byte{shares[0], shares[1]}},
+		{name: "different lengths", xs: []byte{1, 2}, shares: [][]byte{shares[0], shares[1][1:]}},
+		{name: "more indexes than shares", xs: []byte{1, 2}, shares: [][]byte{shares[0]}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := shamirCombine(tt.xs, tt.shares); err == nil {
+				t.Error("combined invalid shares")
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+)
+
+var sealedSecretsResource = v1alpha1.SchemeGroupVersion.WithResource("sealedsecrets")
+
+// localSealedSecret is a SealedSecret read from a file, with the namespace it would be applied to.
+type localSealedSecret struct {
+	file         string
+	sealedSecret v1alpha1.SealedSecret
+}
+
+func Status(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	local, err := readLocalSealedSecrets(args)
+	if err != nil {
+		return err
+	}
+
+	remote, err := listSealedSecrets(cmd.Context(), Namespace, "")
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(local)+len(remote))
+	for k := range local {
+		keys = append(keys, k)
+	}
+
+	for k := range remote {
+		if _, ok := local[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	drifted := 0
+	for _, k := range keys {
+		l, inRepo := local[k]
+		r, inCluster := remote[k]
+		switch {
+		case !inCluster:
+			drifted++
+			fmt.Printf("  local    %s (%s)\n", k, l.file)
+		case !inRepo:
+			drifted++
+			fmt.Printf("  cluster  %s\n", k)
+		default:
+			if differences := sealedSecretDiff(&l.sealedSecret, &r); len(differences) > 0 {
+				drifted++
+				fmt.Printf("  differs  %s (%s): %s\n", k, l.file, strings.Join(differences, ", "))
+			} else {
+				fmt.Printf("  ok       %s (%s)\n", k, l.file)
+			}
+		}
+	}
+
+	fmt.Printf("\nChecked %d sealed secrets: %d in sync, %d drifted\n", len(keys), len(keys)-drifted, drifted)
+	if drifted > 0 {
+		return newError(ExitDrift, "%d sealed secrets differ between the repository and context '%s'", drifted, currentContext())
+	}
+
+	return nil
+}
+
+// readLocalSealedSecrets returns the SealedSecrets in the sealed files below args by namespace/name. The
+// namespace is resolved as for the other commands, and SealedSecrets without one are skipped with a warning.
+func readLocalSealedSecrets(args []string) (map[string]localSealedSecret, error) {
+	// a tree is always checked as a whole
+	files, err := findFiles(args, true, isSealedFile)
+	if err != nil {
+		return nil, err
+	}
+
+	local := make(map[string]localSealedSecret)
+	for _, name := range files {
+		data, err := readFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		m, err := readManifest(data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read documents from %s: %v", name, err)
+		}
+
+		for i, doc := range m.documents {
+			kind, err := documentKind(doc)
+			if err != nil {
+				return nil, fmt.Errorf("unable to unmarshal document %d of %s: %v", i+1, name, err)
+			}
+
+			if kind != "SealedSecret" {
+				continue
+			}
+
+			sealedSecret := v1alpha1.SealedSecret{}
+			err = yaml.Unmarshal(doc, &sealedSecret)
+			if err != nil {
+				return nil, fmt.Errorf("unable to unmarshal secret: %v", err)
+			}
+
+			ns, err := resolveNamespace(sealedSecret.ObjectMeta)
+			if err != nil {
+				WarningLogger.Printf("skipping %s in %s: %v", sealedSecret.Name, name, err)
+				continue
+			}
+
+			k := ns + "/" + sealedSecret.Name
+			if existing, ok := local[k]; ok {
+				WarningLogger.Printf("%s is in both %s and %s, comparing %s", k, existing.file, name, existing.file)
+				continue
+			}
+
+			sealedSecret.Namespace = ns
+			local[k] = localSealedSecret{file: name, sealedSecret: sealedSecret}
+		}
+	}
+
+	return local, nil
+}
+
+// listSealedSecrets returns the SealedSecrets in ns, or every namespace if it is empty, that match the label
+// selector, by namespace/name.
+func listSealedSecrets(ctx context.Context, ns string, selector string) (map[string]v1alpha1.SealedSecret, error) {
+	client, err := getKubeClient()
+	if err != nil {
+		return nil, newError(ExitClusterUnreachable, "unable to get kubernetes client: %v", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(client.client)
+	if err != nil {
+		return nil, newError(ExitClusterUnreachable, "unable to create dynamic client: %v", err)
+	}
+
+	list, err := dynamicClient.Resource(sealedSecretsResource).Namespace(ns).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, newError(ExitClusterUnreachable, "unable to list sealed secrets: %v", err)
+	}
+
+	remote := make(map[string]v1alpha1.SealedSecret, len(list.Items))
+	for _, item := range list.Items {
+		sealedSecret := v1alpha1.SealedSecret{}
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &sealedSecret)
+		if err != nil {
+			return nil, fmt.Errorf("unable to convert sealed secret %s/%s: %v", item.GetNamespace(), item.GetName(), err)
+		}
+
+		remote[sealedSecret.Namespace+"/"+sealedSecret.Name] = sealedSecret
+	}
+
+	return remote, nil
+}
+
+func getSealedSecret(ctx context.Context, ns string, name string) (*v1alpha1.SealedSecret, error) {
+	client, err := getKubeClient()
+	if err != nil {
+		return nil, newError(ExitClusterUnreachable, "unable to get kubernetes client: %v", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(client.client)
+	if err != nil {
+		return nil, newError(ExitClusterUnreachable, "unable to create dynamic client: %v", err)
+	}
+
+	item, err := dynamicClient.Resource(sealedSecretsResource).Namespace(ns).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, newError(ExitNotFound, "sealed secret %s/%s not found", ns, name)
+	} else if err != nil {
+		return nil, newError(ExitClusterUnreachable, "unable to get sealed secret %s/%s: %v", ns, name, err)
+	}
+
+	sealedSecret := &v1alpha1.SealedSecret{}
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, sealedSecret)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert sealed secret %s/%s: %v", ns, name, err)
+	}
+
+	return sealedSecret, nil
+}
+
+// sealedSecretDiff describes how the spec of local differs from remote: the encrypted keys that were added,
+// removed or changed, and whether the template differs.
+func sealedSecretDiff(local *v1alpha1.SealedSecret, remote *v1alpha1.SealedSecret) []string {
+	var differences []string
+	for k, v := range local.Spec.EncryptedData {
+		if remoteValue, ok := remote.Spec.EncryptedData[k]; !ok {
+			differences = append(differences, k+" only in repository")
+		} else if remoteValue != v {
+			differences = append(differences, k+" changed")
+		}
+	}
+
+	for k := range remote.Spec.EncryptedData {
+		if _, ok := local.Spec.EncryptedData[k]; !ok {
+			differences = append(differences, k+" only in cluster")
+		}
+	}
+
+	sort.Strings(differences)
+
+	if !equality.Semantic.DeepEqual(normalizeTemplate(local), normalizeTemplate(remote)) {
+		differences = append(differences, "template")
+	}
+
+	return differences
+}
+
+// normalizeTemplate returns the template of a SealedSecret as the controller would use it. The name and
+// namespace always come from the SealedSecret, and an empty type is Opaque.
+func normalizeTemplate(sealedSecret *v1alpha1.SealedSecret) v1alpha1.SecretTemplateSpec {
+	template := *sealedSecret.Spec.Template.DeepCopy()
+	template.Name = ""
+	template.Namespace = ""
+	template.CreationTimestamp = metav1.Time{}
+	if template.Type == "" {
+		template.Type = corev1.SecretTypeOpaque
+	}
+
+	if len(template.Labels) == 0 {
+		template.Labels = nil
+	}
+
+	if len(template.Annotations) == 0 {
+		template.Annotations = nil
+	}
+
+	if len(template.Data) == 0 {
+		template.Data = nil
+	}
+
+	return template
+}
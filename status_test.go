@@ -0,0 +1,89 @@
+package main
+
+import (
+	"github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newSealedSecret(encryptedData map[string]string, template v1alpha1.SecretTemplateSpec) *v1alpha1.SealedSecret {
+	return &v1alpha1.SealedSecret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "app"},
+		Spec: v1alpha1.SealedSecretSpec{
+			EncryptedData: encryptedData,
+			Template:      template,
+		},
+	}
+}
+
+func TestSealedSecretDiff(t *testing.T) {
+	data := map[string]string{"a": "enc-a", "b": "enc-b"}
+
+	tests := []struct {
+		name   string
+		local  *v1alpha1.SealedSecret
+		remote *v1alpha1.SealedSecret
+		want   []string
+	}{
+		{
+			name:   "in sync",
+			local:  newSealedSecret(data, v1alpha1.SecretTemplateSpec{}),
+			remote: newSealedSecret(data, v1alpha1.SecretTemplateSpec{}),
+		},
+		{
+			name:   "keys added, removed and changed",
+			local:  newSealedSecret(map[string]string{"a": "enc-a2", "c": "enc-c"}, v1alpha1.SecretTemplateSpec{}),
+			remote: newSealedSecret(data, v1alpha1.SecretTemplateSpec{}),
+			want:   []string{"a changed", "b only in cluster", "c only in repository"},
+		},
+		{
+			name:  "server fields of the template are ignored",
+			local: newSealedSecret(data, v1alpha1.SecretTemplateSpec{}),
+			remote: newSealedSecret(data, v1alpha1.SecretTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "db",
+					Namespace:         "app",
+					CreationTimestamp: metav1.NewTime(time.Unix(0, 0)),
+					Labels:            map[string]string{},
+				},
+			}),
+		},
+		{
+			name:   "an empty type is opaque",
+			local:  newSealedSecret(data, v1alpha1.SecretTemplateSpec{Type: corev1.SecretTypeOpaque}),
+			remote: newSealedSecret(data, v1alpha1.SecretTemplateSpec{}),
+		},
+		{
+			name:   "type changed",
+			local:  newSealedSecret(data, v1alpha1.SecretTemplateSpec{Type: corev1.SecretTypeTLS}),
+			remote: newSealedSecret(data, v1alpha1.SecretTemplateSpec{}),
+			want:   []string{"template"},
+		},
+		{
+			name: "labels changed",
+			local: newSealedSecret(data, v1alpha1.SecretTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "db"}},
+			}),
+			remote: newSealedSecret(data, v1alpha1.SecretTemplateSpec{}),
+			want:   []string{"template"},
+		},
+		{
+			name:   "keys and template",
+			local:  newSealedSecret(map[string]string{"a": "enc-a"}, v1alpha1.SecretTemplateSpec{Type: corev1.SecretTypeTLS}),
+			remote: newSealedSecret(data, v1alpha1.SecretTemplateSpec{}),
+			want:   []string{"b only in cluster", "template"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sealedSecretDiff(tt.local, tt.remote)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sealedSecretDiff = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
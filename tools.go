@@ -4,20 +4,42 @@ import (
 	"context"
 	"crypto/rsa"
 	"fmt"
-	"github.com/bitnami-labs/sealed-secrets/pkg/kubeseal"
+	"github.com/bitnami-labs/sealed-secrets/pkg/crypto"
+	"github.com/hfoxy/cobra-starter/flags"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/keyutil"
 	"log"
 	"os"
 	"sigs.k8s.io/yaml"
-	"strings"
+	"sync"
+	"time"
 )
 
 var ErrorLogger = log.New(os.Stderr, "ERROR: ", 0)
+var WarningLogger = log.New(os.Stderr, "WARNING: ", 0)
+
+// DebugLogger only prints with --debug, and to stderr like the others so that it never mixes with output
+// written to stdout.
+var DebugLogger = log.New(os.Stderr, "DEBUG: ", 0)
 
 var ControllerNamespace = metav1.NamespaceSystem
 var ControllerName = "sealed-secrets-controller"
 
+// KeyLabel marks the Secrets holding the controller sealing keys.
+const KeyLabel = "sealedsecrets.bitnami.com/sealed-secrets-key"
+
+// the keys are fetched once per run and shared by every file, which may be processed concurrently
+var publicKey *rsa.PublicKey
+var privateKeys map[string]*rsa.PrivateKey
+var keysMu sync.Mutex
+
+func debugf(format string, v ...interface{}) {
+	if flags.DebugEnabled {
+		DebugLogger.Printf(format, v...)
+	}
+}
+
 func getHome() string {
 	dirname, err := os.UserHomeDir()
 	if err != nil {
@@ -28,58 +50,162 @@ func getHome() string {
 	return dirname
 }
 
-func getPrivateKey(ctx context.Context) (string, error) {
+func getKeySecrets(ctx context.Context) ([]corev1.Secret, error) {
 	client, err := getKubeClient()
 	if err != nil {
-		return "", fmt.Errorf("unable to get kubernetes client: %v", err)
+		return nil, newError(ExitClusterUnreachable, "unable to get kubernetes client: %v", err)
 	}
 
 	secrets, err := client.clientset.CoreV1().Secrets(ControllerNamespace).List(ctx, metav1.ListOptions{
-		LabelSelector: "sealedsecrets.bitnami.com/sealed-secrets-key=active",
+		LabelSelector: KeyLabel + "=active",
 	})
 
 	if err != nil {
-		return "", fmt.Errorf("unable to list secrets: %v", err)
+		return nil, newError(ExitClusterUnreachable, "unable to list secrets: %v", err)
 	}
 
 	if len(secrets.Items) == 0 {
-		return "", fmt.Errorf("no active key found")
+		return nil, newError(ExitNotFound, "no active key found in %s", ControllerNamespace)
+	}
+
+	for i := range secrets.Items {
+		secrets.Items[i].APIVersion = corev1.SchemeGroupVersion.String()
+		secrets.Items[i].Kind = "Secret"
+		secrets.Items[i].ObjectMeta.ManagedFields = nil
+	}
+
+	return secrets.Items, nil
+}
+
+// getPrivateKeys returns the private keys from --private-key, or the active controller keys from the
+// cluster, indexed by the fingerprint of their public key. The keys are only ever held in memory.
+func getPrivateKeys(ctx context.Context) (map[string]*rsa.PrivateKey, error) {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+
+	if privateKeys != nil {
+		return privateKeys, nil
+	}
+
+	keys := make(map[string]*rsa.PrivateKey)
+	if len(PrivateKeys) > 0 {
+		for _, name := range PrivateKeys {
+			data, err := readFile(name)
+			if err != nil {
+				return nil, err
+			}
+
+			err = parsePrivateKeys(data, keys)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse private key %s: %v", name, err)
+			}
+		}
+
+		privateKeys = keys
+		return privateKeys, nil
+	}
+
+	secrets, err := getKeySecrets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, secret := range secrets {
+		err = addPrivateKey(secret.Data[corev1.TLSPrivateKeyKey], keys)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse private key from secret %s: %v", secret.Name, err)
+		}
+	}
+
+	privateKeys = keys
+	return privateKeys, nil
+}
+
+// parsePrivateKeys adds the keys in data to keys. data is either a PEM encoded private key, or one or
+// more Secret documents (or a List of them) holding the key under tls.key.
+func parsePrivateKeys(data []byte, keys map[string]*rsa.PrivateKey) error {
+	if err := addPrivateKey(data, keys); err == nil {
+		return nil
 	}
 
-	output := strings.Builder{}
-	for _, secret := range secrets.Items {
-		secret.APIVersion = corev1.SchemeGroupVersion.String()
-		secret.Kind = "Secret"
-		secret.ObjectMeta.ManagedFields = nil
+	m, err := readManifest(data)
+	if err != nil {
+		return err
+	}
 
-		var data []byte
-		data, err = yaml.Marshal(secret)
+	found := false
+	for _, doc := range m.documents {
+		secret := corev1.Secret{}
+		err = yaml.Unmarshal(doc, &secret)
 		if err != nil {
-			return "", fmt.Errorf("unable to marshal secret: %v", err)
+			return err
 		}
 
-		output.WriteString(string(data))
-		output.WriteString("---\n")
+		if secret.Kind != "Secret" {
+			continue
+		}
+
+		tlsKey, ok := secret.Data[corev1.TLSPrivateKeyKey]
+		if !ok {
+			return fmt.Errorf("secret %s does not contain a %s key", secret.Name, corev1.TLSPrivateKeyKey)
+		}
+
+		err = addPrivateKey(tlsKey, keys)
+		if err != nil {
+			return fmt.Errorf("unable to parse private key from secret %s: %v", secret.Name, err)
+		}
+
+		found = true
+	}
+
+	if !found {
+		return fmt.Errorf("no private keys found")
 	}
 
-	return output.String(), nil
+	return nil
 }
 
-func getPublicKey(ctx context.Context) (*rsa.PublicKey, error) {
-	client, err := getKubeClient()
+func addPrivateKey(data []byte, keys map[string]*rsa.PrivateKey) error {
+	key, err := keyutil.ParsePrivateKeyPEM(data)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get kubernetes client: %v", err)
+		return err
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return fmt.Errorf("unexpected private key type %T", key)
 	}
 
-	r, err := kubeseal.OpenCert(ctx, client, ControllerNamespace, ControllerName, "")
+	fingerprint, err := crypto.PublicKeyFingerprint(&rsaKey.PublicKey)
 	if err != nil {
-		return nil, fmt.Errorf("unable to open cert: %v", err)
+		return err
 	}
 
-	key, err := kubeseal.ParseKey(r)
+	keys[fingerprint] = rsaKey
+	return nil
+}
+
+// getPublicKey returns the public key to seal with, see getCert. It refuses to seal with an expired
+// certificate and warns when the certificate is about to expire.
+func getPublicKey(ctx context.Context) (*rsa.PublicKey, error) {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+
+	if publicKey != nil {
+		return publicKey, nil
+	}
+
+	cert, source, err := getCert(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse key: %v", err)
+		return nil, err
 	}
 
-	return key, nil
+	if time.Now().After(cert.NotAfter) {
+		return nil, fmt.Errorf("certificate %s expired on %s", source, cert.NotAfter.Format(time.RFC3339))
+	}
+
+	warnExpiry(cert, source)
+
+	publicKey = cert.PublicKey.(*rsa.PublicKey)
+	return publicKey, nil
 }
@@ -2,159 +2,346 @@ package main
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
-	"github.com/bitnami-labs/sealed-secrets/pkg/kubeseal"
+	"github.com/bitnami-labs/sealed-secrets/pkg/crypto"
 	"github.com/spf13/cobra"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/client-go/kubernetes/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"os"
 	"sigs.k8s.io/yaml"
+	"sort"
 	"strings"
+	"text/template"
 )
 
-var ErrStop = fmt.Errorf("stop")
 var Decode bool
+var PrivateKeys []string
+var FromCluster string
+var UnsealAll bool
 
 const NamespaceKey = "sealedsecrets.hfox.me/namespace"
 
 func Unseal(cmd *cobra.Command, args []string) error {
+	if FromCluster != "" || UnsealAll {
+		if len(args) > 0 {
+			return fmt.Errorf("cannot specify files with --from-cluster or --all")
+		}
+
+		return unsealFromCluster(cmd)
+	}
+
 	if len(args) == 0 {
 		return cmd.Help()
 	}
 
-	if len(args) > 1 {
+	if len(args) > 1 || Recursive || isDir(args[0]) {
 		if OutputFile != "" {
 			return fmt.Errorf("cannot specify output file with multiple input files")
 		}
 
-		for _, arg := range args {
-			outputName := strings.TrimSuffix(arg, ".yaml") + ".unsealed.yaml"
-			err := unseal(cmd, arg, outputName)
-			if err != nil {
-				if errors.Is(err, ErrStop) {
-					return nil
-				}
-
-				return err
-			}
-		}
-
-		return nil
-	} else {
-		outputName := OutputFile
-		if outputName == "" {
-			outputName = strings.TrimSuffix(args[0], ".yaml") + ".unsealed.yaml"
-		}
-
-		err := unseal(cmd, args[0], outputName)
+		files, err := findFiles(args, Recursive, isSealedFile)
 		if err != nil {
-			if errors.Is(err, ErrStop) {
-				return nil
-			}
-
 			return err
 		}
+
+		return runBatch(files, unsealOutputName, !KeepGoing, func(input string, output string) error {
+			return unseal(cmd, input, output)
+		})
 	}
 
-	return nil
+	outputName := OutputFile
+	if outputName == "" {
+		outputName = unsealOutputName(args[0])
+	}
+
+	return unseal(cmd, args[0], outputName)
 }
 
-func unsealSecret(cmd *cobra.Command, name string) (string, *v1alpha1.SealedSecret, error) {
-	client, err := getKubeClient()
+// unsealedSecret is a SealedSecret document of a manifest together with its decrypted Secret.
+type unsealedSecret struct {
+	index        int
+	sealedSecret *v1alpha1.SealedSecret
+	secret       *corev1.Secret
+}
+
+// unsealSecrets decrypts every SealedSecret in the file, returning the file's manifest alongside them.
+func unsealSecrets(cmd *cobra.Command, name string) (*manifest, []unsealedSecret, error) {
+	fileName := name
+	data, err := readFile(fileName)
 	if err != nil {
-		return "", nil, fmt.Errorf("unable to get kubernetes client: %v", err)
+		return nil, nil, err
 	}
 
-	key, err := getPrivateKey(cmd.Context())
+	m, err := readManifest(data)
 	if err != nil {
-		return "", nil, err
+		return nil, nil, fmt.Errorf("unable to read documents from %s: %v", fileName, err)
 	}
 
-	fileName := name
-	data, err := os.ReadFile(fileName)
-	if err != nil {
-		ErrorLogger.Printf("unable to read file: %v", err)
-		return "", nil, ErrStop
+	var keys map[string]*rsa.PrivateKey
+	var secrets []unsealedSecret
+	for i, doc := range m.documents {
+		kind, err := documentKind(doc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to unmarshal document %d of %s: %v", i+1, fileName, err)
+		}
+
+		if kind != "SealedSecret" {
+			continue
+		}
+
+		sealedSecret := v1alpha1.SealedSecret{}
+		err = yaml.Unmarshal(doc, &sealedSecret)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to unmarshal secret: %v", err)
+		}
+
+		ns, err := resolveNamespace(sealedSecret.ObjectMeta)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		sealedSecret.ObjectMeta.Namespace = ns
+
+		if len(PrivateKeys) > 0 {
+			fmt.Printf("Unsealing '%s' from '%s' with private keys from %s, namespace '%s'\n", sealedSecret.Name, fileName, strings.Join(PrivateKeys, ", "), ns)
+		} else {
+			client, err := getKubeClient()
+			if err != nil {
+				return nil, nil, newError(ExitClusterUnreachable, "unable to get kubernetes client: %v", err)
+			}
+
+			fmt.Printf("Unsealing '%s' from '%s' in context '%s', namespace '%s'\n", sealedSecret.Name, fileName, client.context, ns)
+		}
+
+		if keys == nil {
+			keys, err = getPrivateKeys(cmd.Context())
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		secret, err := decryptSealedSecret(&sealedSecret, keys)
+		if err != nil {
+			return nil, nil, newError(ExitDecryptFailed, "unable to unseal secret %s from %s: %v", sealedSecret.Name, name, err)
+		}
+
+		secrets = append(secrets, unsealedSecret{
+			index:        i,
+			sealedSecret: &sealedSecret,
+			secret:       secret,
+		})
 	}
 
-	sealedSecret := v1alpha1.SealedSecret{}
-	err = yaml.Unmarshal(data, &sealedSecret)
-	if err != nil {
-		ErrorLogger.Printf("unable to unmarshal secret: %v", err)
-		return "", nil, ErrStop
+	if len(secrets) == 0 {
+		return nil, nil, newError(ExitNotFound, "no sealed secrets found in %s", fileName)
 	}
 
+	return m, secrets, nil
+}
+
+// resolveNamespace returns the namespace from --namespace, the object's metadata or the NamespaceKey
+// annotation, in that order.
+func resolveNamespace(meta metav1.ObjectMeta) (string, error) {
 	ns := Namespace
-	nsAnno, ok := sealedSecret.ObjectMeta.Annotations[NamespaceKey]
-	if ns == "" && sealedSecret.Namespace == "" && (!ok || nsAnno == "") {
-		ErrorLogger.Printf("unable to determine namespace\n")
-		return "", nil, ErrStop
-	} else if ns == "" && sealedSecret.Namespace != "" {
+	nsAnno, ok := meta.Annotations[NamespaceKey]
+	if ns == "" && meta.Namespace == "" && (!ok || nsAnno == "") {
+		return "", newError(ExitNamespaceUndetermined, "unable to determine namespace for %s, use --namespace", meta.Name)
+	} else if ns == "" && meta.Namespace != "" {
 		fmt.Printf("Using namespace from secret\n")
-		ns = sealedSecret.Namespace
+		ns = meta.Namespace
 	} else if ns == "" && ok && nsAnno != "" {
 		fmt.Printf("Using namespace from annotation\n")
 		ns = nsAnno
 	}
 
-	if sealedSecret.ObjectMeta.Namespace != ns {
-		sealedSecret.ObjectMeta.Namespace = ns
-		data, err = yaml.Marshal(sealedSecret)
+	return ns, nil
+}
+
+// decryptSealedSecret decrypts every key of sealedSecret with the given private keys, the same way the
+// controller would, without the keys ever leaving memory.
+func decryptSealedSecret(sealedSecret *v1alpha1.SealedSecret, keys map[string]*rsa.PrivateKey) (*corev1.Secret, error) {
+	if sealedSecret.Spec.Data != nil {
+		return nil, fmt.Errorf("using deprecated 'data' field, reseal with 'encryptedData'")
+	}
+
+	secret := &corev1.Secret{}
+	sealedSecret.Spec.Template.ObjectMeta.DeepCopyInto(&secret.ObjectMeta)
+	secret.APIVersion = corev1.SchemeGroupVersion.String()
+	secret.Kind = "Secret"
+	secret.Type = sealedSecret.Spec.Template.Type
+	secret.Immutable = sealedSecret.Spec.Template.Immutable
+	secret.Name = sealedSecret.Name
+	secret.Namespace = sealedSecret.Namespace
+	secret.Data = make(map[string][]byte, len(sealedSecret.Spec.EncryptedData))
+
+	label := v1alpha1.EncryptionLabel(sealedSecret.Namespace, sealedSecret.Name, v1alpha1.SecretScope(sealedSecret))
+
+	values := make(map[string]string, len(sealedSecret.Spec.EncryptedData))
+	var errs []error
+	for k, v := range sealedSecret.Spec.EncryptedData {
+		ciphertext, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", k, err))
+			continue
+		}
+
+		plaintext, err := crypto.HybridDecrypt(rand.Reader, keys, ciphertext, label)
 		if err != nil {
-			ErrorLogger.Printf("unable to remarshal secret: %v", err)
-			return "", nil, ErrStop
+			errs = append(errs, fmt.Errorf("%s: %v", k, err))
+			continue
 		}
+
+		secret.Data[k] = plaintext
+		values[k] = string(plaintext)
 	}
 
-	fmt.Printf("Unsealing '%s' in context '%s', namespace '%s'\n", fileName, client.context, ns)
+	for k, v := range sealedSecret.Spec.Template.Data {
+		t, err := template.New(k).Parse(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", k, err))
+			continue
+		}
+
+		out := &bytes.Buffer{}
+		err = t.Execute(out, values)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", k, err))
+			continue
+		}
+
+		secret.Data[k] = out.Bytes()
+	}
 
-	temp, err := os.CreateTemp(os.TempDir(), "ss-")
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return secret, nil
+}
+
+func unseal(cmd *cobra.Command, arg string, outputName string) error {
+	m, secrets, err := unsealSecrets(cmd, arg)
 	if err != nil {
-		ErrorLogger.Printf("unable to create temp file: %v", err)
-		return "", nil, ErrStop
+		return err
 	}
 
-	defer func() {
-		removeErr := os.Remove(temp.Name())
-		if removeErr != nil {
-			ErrorLogger.Printf("unable to remove temp file: %v", removeErr)
+	for _, s := range secrets {
+		m.documents[s.index], err = formatUnsealed(s.secret, s.sealedSecret)
+		if err != nil {
+			return err
 		}
-	}()
+	}
 
-	_, err = temp.WriteString(key)
+	b, err := m.bytes(fileFormat(outputName))
 	if err != nil {
-		ErrorLogger.Printf("unable to write to temp file: %v", err)
-		return "", nil, ErrStop
+		return fmt.Errorf("unable to marshal unsealed secrets: %v", err)
 	}
 
-	reader := bytes.NewReader(data)
-
-	w := &bytes.Buffer{}
-	err = kubeseal.UnsealSealedSecret(w, reader, []string{temp.Name()}, "yaml", scheme.Codecs)
+	err = os.WriteFile(outputName, b, 0644)
 	if err != nil {
-		ErrorLogger.Printf("unable to unseal secret %s: %v", name, err)
-		return "", nil, ErrStop
+		return fmt.Errorf("unable to write to file %s: %v", outputName, err)
 	}
 
-	out := strings.TrimPrefix(w.String(), "---\n")
-	return out, &sealedSecret, nil
+	fmt.Printf("Unsealed secret written to %s\n", outputName)
+	return nil
 }
 
-func unseal(cmd *cobra.Command, arg string, outputName string) error {
-	out, sealedSecret, err := unsealSecret(cmd, arg)
+// unsealFromCluster unseals the SealedSecret named by --from-cluster, or every SealedSecret in the namespace
+// with --all, as they are in the cluster. The output goes to stdout unless --output is set.
+func unsealFromCluster(cmd *cobra.Command) error {
+	err := checkOutputFile(OutputFile)
 	if err != nil {
 		return err
 	}
 
-	secret := corev1.Secret{}
-	err = yaml.Unmarshal([]byte(out), &secret)
+	var sealedSecrets []v1alpha1.SealedSecret
+	if UnsealAll {
+		if FromCluster != "" {
+			return fmt.Errorf("cannot specify --from-cluster with --all")
+		}
+
+		ns, err := contextNamespace()
+		if err != nil {
+			return err
+		}
+
+		remote, err := listSealedSecrets(cmd.Context(), ns, "")
+		if err != nil {
+			return err
+		}
+
+		if len(remote) == 0 {
+			return newError(ExitNotFound, "no sealed secrets found in %s", ns)
+		}
+
+		for _, sealedSecret := range remote {
+			sealedSecrets = append(sealedSecrets, sealedSecret)
+		}
+
+		sort.Slice(sealedSecrets, func(i, j int) bool {
+			return sealedSecrets[i].Name < sealedSecrets[j].Name
+		})
+	} else {
+		ns, name, ok := strings.Cut(FromCluster, "/")
+		if !ok {
+			name = ns
+			ns, err = contextNamespace()
+			if err != nil {
+				return err
+			}
+		}
+
+		sealedSecret, err := getSealedSecret(cmd.Context(), ns, name)
+		if err != nil {
+			return err
+		}
+
+		sealedSecrets = append(sealedSecrets, *sealedSecret)
+	}
+
+	keys, err := getPrivateKeys(cmd.Context())
 	if err != nil {
-		ErrorLogger.Printf("unable to unmarshal unsealed secret: %v", err)
-		return ErrStop
+		return err
 	}
 
+	m := &manifest{}
+	for i := range sealedSecrets {
+		secret, err := decryptSealedSecret(&sealedSecrets[i], keys)
+		if err != nil {
+			return newError(ExitDecryptFailed, "unable to unseal secret %s/%s: %v", sealedSecrets[i].Namespace, sealedSecrets[i].Name, err)
+		}
+
+		doc, err := formatUnsealed(secret, &sealedSecrets[i])
+		if err != nil {
+			return err
+		}
+
+		m.documents = append(m.documents, doc)
+	}
+
+	data, err := m.bytes(fileFormat(OutputFile))
+	if err != nil {
+		return fmt.Errorf("unable to marshal unsealed secrets: %v", err)
+	}
+
+	status, err := writeOutput(data)
+	if err != nil {
+		return fmt.Errorf("unable to write unsealed secrets: %v", err)
+	}
+
+	fmt.Fprintf(status, "Unsealed %d secret(s) from context '%s'\n", len(sealedSecrets), currentContext())
+	return nil
+}
+
+// formatUnsealed applies --decode and the namespace handling to the output of unsealSecret.
+func formatUnsealed(unsealed *corev1.Secret, sealedSecret *v1alpha1.SealedSecret) ([]byte, error) {
+	secret := unsealed.DeepCopy()
+
 	if Decode {
 		keys := make([]string, 0, len(secret.Data))
 		for k := range secret.Data {
@@ -172,6 +359,10 @@ func unseal(cmd *cobra.Command, arg string, outputName string) error {
 
 	if sealedSecret.ObjectMeta.Namespace == "" {
 		secret.ObjectMeta.Namespace = Namespace
+		if secret.ObjectMeta.Annotations == nil {
+			secret.ObjectMeta.Annotations = make(map[string]string)
+		}
+
 		secret.ObjectMeta.Annotations[NamespaceKey] = Namespace
 	} else {
 		secret.ObjectMeta.Namespace = sealedSecret.ObjectMeta.Namespace
@@ -179,18 +370,8 @@ func unseal(cmd *cobra.Command, arg string, outputName string) error {
 
 	b, err := yaml.Marshal(secret)
 	if err != nil {
-		ErrorLogger.Printf("unable to marshal unsealed secret: %v", err)
-		return ErrStop
-	}
-
-	out = string(b)
-
-	err = os.WriteFile(outputName, []byte(out), 0644)
-	if err != nil {
-		ErrorLogger.Printf("unable to write to file: %v", err)
-		return ErrStop
+		return nil, fmt.Errorf("unable to marshal unsealed secret: %v", err)
 	}
 
-	fmt.Printf("Unsealed secret written to %s\n", outputName)
-	return nil
+	return b, nil
 }
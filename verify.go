@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/bitnami-labs/sealed-secrets/pkg/apis/sealedsecrets/v1alpha1"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/net"
+	"sigs.k8s.io/yaml"
+)
+
+func Verify(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return cmd.Help()
+	}
+
+	files, err := findFiles(args, Recursive, isSealedFile)
+	if err != nil {
+		return err
+	}
+
+	client, err := getKubeClient()
+	if err != nil {
+		return newError(ExitClusterUnreachable, "unable to get kubernetes client: %v", err)
+	}
+
+	portName, err := controllerPortName(cmd.Context(), client)
+	if err != nil {
+		return err
+	}
+
+	// every file is checked so that a CI run reports all of the failures at once
+	return runBatch(files, nil, false, func(input string, output string) error {
+		return verify(cmd, client, portName, input)
+	})
+}
+
+// verify asks the controller whether it is able to decrypt every SealedSecret in the file.
+func verify(cmd *cobra.Command, client *ClientConfig, portName string, arg string) error {
+	data, err := readFile(arg)
+	if err != nil {
+		return err
+	}
+
+	m, err := readManifest(data)
+	if err != nil {
+		return fmt.Errorf("unable to read documents from %s: %v", arg, err)
+	}
+
+	found := false
+	for i, doc := range m.documents {
+		kind, err := documentKind(doc)
+		if err != nil {
+			return fmt.Errorf("unable to unmarshal document %d of %s: %v", i+1, arg, err)
+		}
+
+		if kind != "SealedSecret" {
+			continue
+		}
+
+		found = true
+		sealedSecret := v1alpha1.SealedSecret{}
+		err = yaml.Unmarshal(doc, &sealedSecret)
+		if err != nil {
+			return fmt.Errorf("unable to unmarshal secret: %v", err)
+		}
+
+		sealedSecret.ObjectMeta.Namespace, err = resolveNamespace(sealedSecret.ObjectMeta)
+		if err != nil {
+			return err
+		}
+
+		err = verifySealedSecret(cmd.Context(), client, portName, &sealedSecret)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !found {
+		return newError(ExitNotFound, "no sealed secrets found in %s", arg)
+	}
+
+	return nil
+}
+
+// controllerPortName returns the name of the first port of the controller service, which its API is proxied
+// through.
+func controllerPortName(ctx context.Context, client *ClientConfig) (string, error) {
+	service, err := client.clientset.CoreV1().Services(ControllerNamespace).Get(ctx, ControllerName, metav1.GetOptions{})
+	if err != nil {
+		return "", newError(ExitClusterUnreachable, "unable to get controller service %s/%s, use --controller-name and --controller-namespace: %v", ControllerNamespace, ControllerName, err)
+	} else if len(service.Spec.Ports) == 0 {
+		return "", newError(ExitClusterUnreachable, "controller service %s/%s has no ports", ControllerNamespace, ControllerName)
+	}
+
+	return service.Spec.Ports[0].Name, nil
+}
+
+// verifySealedSecret posts a SealedSecret to the verify endpoint of the controller, which answers with a
+// conflict if none of its keys decrypt it. This is what kubeseal.ValidateSealedSecret does, but that formats
+// the API error into a string, which would leave only its text to tell a conflict apart.
+func verifySealedSecret(ctx context.Context, client *ClientConfig, portName string, sealedSecret *v1alpha1.SealedSecret) error {
+	content, err := json.Marshal(sealedSecret)
+	if err != nil {
+		return fmt.Errorf("unable to marshal secret: %v", err)
+	}
+
+	err = client.clientset.CoreV1().RESTClient().Post().
+		Namespace(ControllerNamespace).
+		Resource("services").
+		SubResource("proxy").
+		Name(net.JoinSchemeNamePort("http", ControllerName, portName)).
+		Suffix("/v1/verify").
+		Body(content).
+		Do(ctx).
+		Error()
+
+	if apierrors.IsConflict(err) {
+		return newError(ExitDecryptFailed, "controller cannot decrypt %s/%s", sealedSecret.Namespace, sealedSecret.Name)
+	} else if err != nil {
+		return newError(ExitClusterUnreachable, "unable to verify %s/%s: %v", sealedSecret.Namespace, sealedSecret.Name, err)
+	}
+
+	return nil
+}